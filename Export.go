@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+// Supported export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+func ExportLogLines(w io.Writer, lines []logline.LogLine, format string) error {
+	// exportLogLines writes lines to w as CSV or JSON
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"Timestamp", "LineType", "Result", "Domain", "Requester", "Upstream"}); err != nil {
+			return err
+		}
+		for _, ll := range lines {
+			record := []string{
+				ll.Timestamp.Format(time.RFC3339),
+				ll.LineType,
+				ll.Result,
+				ll.Domain,
+				ll.Requester,
+				ll.Upstream,
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lines)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
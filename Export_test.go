@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+const exportLog = `Sep 17 15:04:01 dnsmasq[711]: query[A] example.com from 192.168.1.10
+Sep 17 15:04:01 dnsmasq[711]: forwarded example.com to 1.1.1.1
+Sep 17 15:04:02 dnsmasq[711]: gravity blocked ads.example.net is 0.0.0.0
+`
+
+func parseExportLog(t *testing.T) []logline.LogLine {
+	lines, err := logline.ReadLogLines(strings.NewReader(exportLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logline.ResolveYears(lines, 2021)
+	return lines
+}
+
+func TestExportCSVColumns(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportLogLines(&buf, parseExportLog(t), FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"Timestamp", "LineType", "Result", "Domain", "Requester", "Upstream"},
+		{stamp(15, 4, 1), "query[A]", "", "example.com", "192.168.1.10", ""},
+		{stamp(15, 4, 1), "forwarded", "", "example.com", "", "1.1.1.1"},
+		{stamp(15, 4, 2), "gravity blocked", "0.0.0.0", "ads.example.net", "", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %q\nwant %q", records, want)
+	}
+}
+
+func stamp(hour, min, sec int) string {
+	// the log is in local time, so the exported offset depends on where the test runs
+	return time.Date(2021, time.September, 17, hour, min, sec, 0, time.Local).Format(time.RFC3339)
+}
+
+func TestExportJSONKeepsLoggedText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportLogLines(&buf, parseExportLog(t), FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var exported []logline.LogLine
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported) != 3 {
+		t.Fatalf("got %d entries, want 3", len(exported))
+	}
+	if exported[0].LineType != "query[A]" {
+		t.Errorf("LineType = %q, want %q", exported[0].LineType, "query[A]")
+	}
+	if want := strings.Split(exportLog, "\n")[0]; exported[0].Line != want {
+		t.Errorf("Line = %q, want %q", exported[0].Line, want)
+	}
+}
+
+func TestExportUnsupportedFormat(t *testing.T) {
+	if err := ExportLogLines(&bytes.Buffer{}, nil, "xml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nxadm/tail"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+// rotationPattern matches the rotation number logrotate appends to old logs, e.g. pihole.log.2.gz
+var rotationPattern = regexp.MustCompile(`\.(\d+)(\.gz)?$`)
+
+// loadedLog is the result of reading one or more log files
+type loadedLog struct {
+	Lines  []logline.LogLine // Parsed lines from every file, oldest first
+	Newest string            // Path of the newest file read, which is the one -follow tails
+	Offset int64             // Offset just past the last byte read from Newest
+}
+
+func isGzip(path string) bool {
+	// isGzip reports whether path names a gzip-compressed log
+	return strings.HasSuffix(path, ".gz")
+}
+
+func rotation(path string) int {
+	// rotation returns the logrotate number of path, or 0 for the current (unrotated) log
+	m := rotationPattern.FindStringSubmatch(path)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+func expandLogFiles(pattern string) ([]string, error) {
+	// expandLogFiles expands a path or glob like pihole.log* into the files it names, oldest first
+	// rotated files are ordered by descending rotation number, so pihole.log.2.gz comes before pihole.log.1 and pihole.log
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		// let the open report a useful error for a plain path that doesn't exist
+		return []string{pattern}, nil
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		return rotation(paths[i]) > rotation(paths[j])
+	})
+	return paths, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// lineCountingReader counts the newlines read through it, reporting the running total to progress after each read
+type lineCountingReader struct {
+	r        io.Reader
+	lines    int
+	progress func(lines int)
+}
+
+func (l *lineCountingReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.lines += bytes.Count(p[:n], []byte{'\n'})
+	l.progress(l.lines)
+	return n, err
+}
+
+func readLogFile(path string, progress func(lines int)) ([]logline.LogLine, int64, error) {
+	// readLogFile parses the whole file at path, transparently decompressing .gz files
+	// it also returns the number of bytes read from the file so a follower can pick up from there
+	// progress is called with the number of lines read so far as the file is parsed
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	counter := &countingReader{r: f}
+	var r io.Reader = counter
+	if isGzip(path) {
+		gz, gzError := gzip.NewReader(counter)
+		if gzError != nil {
+			return nil, 0, fmt.Errorf("%v: %w", path, gzError)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	logLines, parseError := logline.ReadLogLines(&lineCountingReader{r: r, progress: progress})
+	if parseError != nil {
+		return nil, 0, fmt.Errorf("%v: %w", path, parseError)
+	}
+	return logLines, counter.n, nil
+}
+
+func loadLogFiles(pattern string, newestYear int, progress func(lines int)) (loadedLog, error) {
+	// loadLogFiles reads every file matched by pattern and merges their lines in chronological order
+	// timestamps are placed in newestYear counting back from the newest line, or if newestYear is 0, in a year guessed by NewestYear
+	// progress, if not nil, is called with the total number of lines read so far across every file
+	paths, err := expandLogFiles(pattern)
+	if err != nil {
+		return loadedLog{}, err
+	}
+
+	var loaded loadedLog
+	linesRead := 0
+	for _, path := range paths {
+		fileLines := 0
+		logLines, offset, readError := readLogFile(path, func(lines int) {
+			fileLines = lines
+			if progress != nil {
+				progress(linesRead + lines)
+			}
+		})
+		if readError != nil {
+			return loadedLog{}, readError
+		}
+		linesRead += fileLines
+		loaded.Lines = append(loaded.Lines, logLines...)
+		loaded.Newest = path
+		loaded.Offset = offset
+	}
+
+	if n := len(loaded.Lines); n > 0 {
+		if newestYear == 0 {
+			newestYear = logline.NewestYear(loaded.Lines[n-1].Timestamp, time.Now())
+		}
+		logline.ResolveYears(loaded.Lines, newestYear)
+	}
+	return loaded, nil
+}
+
+func loadDomainSet(path string) (map[string]bool, error) {
+	// loadDomainSet reads a file of domains, one per line, into a set keyed by lowercase domain
+	// blank lines and lines starting with # are ignored
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		domain := strings.TrimSpace(scanner.Text())
+		if domain == "" || strings.HasPrefix(domain, "#") {
+			continue
+		}
+		set[strings.ToLower(domain)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func followLogFile(path string, offset int64) (*tail.Tail, error) {
+	// followLogFile opens a tail on path starting at offset that keeps emitting lines as they are written
+	// the tail's logger is discarded since any output to stderr would corrupt the TUI
+	if isGzip(path) {
+		return nil, fmt.Errorf("cannot follow compressed log %v", path)
+	}
+	return tail.TailFile(path, tail.Config{
+		Follow:    true,
+		ReOpen:    true,
+		MustExist: true,
+		Location:  &tail.SeekInfo{Offset: offset, Whence: io.SeekStart},
+		Logger:    tail.DiscardingLogger,
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLogFile(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if !isGzip(path) {
+		if _, err := f.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLogFilesRotated(t *testing.T) {
+	dir := t.TempDir()
+	// written newest first, so neither the glob's nor the filesystem's order is oldest first
+	files := []struct {
+		name string
+		text string
+	}{
+		{"pihole.log", "Sep 17 15:04:04 dnsmasq[711]: query[A] d.example from 10.0.0.2\n"},
+		{"pihole.log.1", "Sep 17 15:04:03 dnsmasq[711]: query[A] c.example from 10.0.0.2\n"},
+		{"pihole.log.2.gz", "Sep 17 15:04:02 dnsmasq[711]: query[A] b.example from 10.0.0.2\n"},
+		{"pihole.log.10.gz", "Sep 17 15:04:01 dnsmasq[711]: query[A] a.example from 10.0.0.2\n"},
+	}
+	for _, f := range files {
+		writeLogFile(t, filepath.Join(dir, f.name), f.text)
+	}
+
+	progress := 0
+	loaded, err := loadLogFiles(filepath.Join(dir, "pihole.log*"), 2021, func(lines int) { progress = lines })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var domains []string
+	for _, ll := range loaded.Lines {
+		domains = append(domains, ll.Domain)
+	}
+	want := []string{"a.example", "b.example", "c.example", "d.example"}
+	if len(domains) != len(want) {
+		t.Fatalf("loaded domains %q, want %q", domains, want)
+	}
+	for i := range want {
+		if domains[i] != want[i] {
+			t.Fatalf("loaded domains %q, want %q", domains, want)
+		}
+	}
+	if year := loaded.Lines[0].Timestamp.Year(); year != 2021 {
+		t.Errorf("lines are in year %d, want 2021", year)
+	}
+	if progress != len(files) {
+		t.Errorf("progress reported %d lines, want %d", progress, len(files))
+	}
+
+	if newest := filepath.Join(dir, "pihole.log"); loaded.Newest != newest {
+		t.Errorf("Newest = %v, want %v", loaded.Newest, newest)
+	}
+	if offset := int64(len(files[0].text)); loaded.Offset != offset {
+		t.Errorf("Offset = %d, want %d, the size of the newest file", loaded.Offset, offset)
+	}
+}
+
+func TestLoadLogFilesBadGzip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pihole.log.2.gz")
+	if err := os.WriteFile(path, []byte("not compressed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadLogFiles(path, 2021, nil); err == nil {
+		t.Errorf("loadLogFiles(%v) read a file that isn't gzip", path)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "github.com/tydar/pihole-log-explorer/pihole/logline"
+
+func NextMatch(lines []logline.LogLine, match logline.FilterFunc, from int, forward bool) int {
+	// nextMatch returns the index of the first line after from (or before it, if !forward) that match includes
+	// the search wraps around the ends of lines, finishing on from itself, and returns -1 if nothing matches
+	// a from outside of lines (e.g. -1 for no selection) starts the search at the first or last line
+	n := len(lines)
+	if from < 0 || from >= n {
+		if forward {
+			from = -1
+		} else {
+			from = n
+		}
+	}
+
+	for step := 1; step <= n; step++ {
+		i := from + step
+		if !forward {
+			i = from - step
+		}
+		i = (i%n + n) % n
+		if match(lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
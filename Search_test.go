@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+func TestNextMatch(t *testing.T) {
+	// lines 1 and 3 are blocked
+	lines := []logline.LogLine{
+		{LineType: logline.A}, {LineType: logline.Blocked}, {LineType: logline.Reply},
+		{LineType: logline.Blocked}, {LineType: logline.Forwarded},
+	}
+	blocked := func(ll logline.LogLine) bool { return ll.LineType == logline.Blocked }
+	none := func(logline.LogLine) bool { return false }
+
+	tests := []struct {
+		name    string
+		lines   []logline.LogLine
+		match   logline.FilterFunc
+		from    int
+		forward bool
+		want    int
+	}{
+		{"forward", lines, blocked, 1, true, 3},
+		{"backward", lines, blocked, 3, false, 1},
+		{"forward from a non-match", lines, blocked, 2, true, 3},
+		{"backward from a non-match", lines, blocked, 2, false, 1},
+		{"forward wraps", lines, blocked, 3, true, 1},
+		{"backward wraps", lines, blocked, 1, false, 3},
+		{"forward past the last match wraps", lines, blocked, 4, true, 1},
+		{"forward from no selection", lines, blocked, -1, true, 1},
+		{"backward from no selection", lines, blocked, -1, false, 3},
+		{"forward from past the end", lines, blocked, len(lines), true, 1},
+		{"backward from past the end", lines, blocked, len(lines), false, 3},
+		{"only match is from", lines[:2], blocked, 1, true, 1},
+		{"no match", lines, none, 0, true, -1},
+		{"no match backward", lines, none, 0, false, -1},
+		{"empty", nil, blocked, 0, true, -1},
+		{"empty from no selection", []logline.LogLine{}, blocked, -1, false, -1},
+	}
+	for _, tt := range tests {
+		if got := NextMatch(tt.lines, tt.match, tt.from, tt.forward); got != tt.want {
+			t.Errorf("%v: NextMatch(from %d, forward %v) = %d, want %d", tt.name, tt.from, tt.forward, got, tt.want)
+		}
+	}
+}
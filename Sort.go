@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+// SortKey is a LogLine field that SortLogLines can order by
+type SortKey int
+
+// Fields LogLines can be sorted by
+const (
+	SortByTimestamp SortKey = iota
+	SortByDomain
+	SortByType
+)
+
+func (k SortKey) String() string {
+	switch k {
+	case SortByTimestamp:
+		return "Timestamp"
+	case SortByDomain:
+		return "Domain"
+	case SortByType:
+		return "Type"
+	default:
+		return "Unknown"
+	}
+}
+
+func (k SortKey) less(a, b logline.LogLine) bool {
+	// less reports whether a sorts before b in ascending order by k
+	switch k {
+	case SortByDomain:
+		return a.Domain < b.Domain
+	case SortByType:
+		return a.LineType < b.LineType
+	default:
+		return a.Timestamp.Before(b.Timestamp)
+	}
+}
+
+func SortLogLines(lines []logline.LogLine, by SortKey, desc bool) []logline.LogLine {
+	// sortLogLines returns a copy of lines sorted by the field by, leaving lines untouched
+	// the sort is stable, so lines that compare equal keep their original order
+	sorted := make([]logline.LogLine, len(lines))
+	copy(sorted, lines)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if desc {
+			return by.less(sorted[j], sorted[i])
+		}
+		return by.less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+func insertSorted(sorted []logline.LogLine, ll logline.LogLine, by SortKey, desc bool) ([]logline.LogLine, int) {
+	// insertSorted inserts ll into sorted, a result of SortLogLines with the same by and desc, and returns it with ll's index
+	// ll goes after the lines it compares equal to, just where SortLogLines would put it had it been appended before sorting
+	// like append, this may modify sorted's backing array, so it must not be shared
+	i := sort.Search(len(sorted), func(i int) bool {
+		if desc {
+			return by.less(sorted[i], ll)
+		}
+		return by.less(ll, sorted[i])
+	})
+	sorted = append(sorted, logline.LogLine{})
+	copy(sorted[i+1:], sorted[i:])
+	sorted[i] = ll
+	return sorted, i
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+// sortFixture has duplicate domains, types and timestamps so stability shows; Line identifies each entry
+func sortFixture() []logline.LogLine {
+	at := func(min int) time.Time { return time.Date(2021, time.September, 17, 15, min, 0, 0, time.Local) }
+	return []logline.LogLine{
+		{Line: "1", Timestamp: at(3), LineType: logline.Reply, Domain: "b.example"},
+		{Line: "2", Timestamp: at(1), LineType: logline.A, Domain: "a.example"},
+		{Line: "3", Timestamp: at(3), LineType: logline.A, Domain: "b.example"},
+		{Line: "4", Timestamp: at(2), LineType: logline.Reply, Domain: "a.example"},
+	}
+}
+
+func order(lines []logline.LogLine) string {
+	var ids string
+	for _, ll := range lines {
+		ids += ll.Line
+	}
+	return ids
+}
+
+func TestSortLogLines(t *testing.T) {
+	tests := []struct {
+		by   SortKey
+		desc bool
+		want string
+	}{
+		{SortByTimestamp, false, "2413"},
+		{SortByTimestamp, true, "1342"},
+		{SortByDomain, false, "2413"},
+		{SortByDomain, true, "1324"},
+		{SortByType, false, "2314"},
+		{SortByType, true, "1423"},
+	}
+	for _, tt := range tests {
+		if got := order(SortLogLines(sortFixture(), tt.by, tt.desc)); got != tt.want {
+			t.Errorf("SortLogLines(by %v, desc %v) = %v, want %v", tt.by, tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSortLogLinesLeavesInput(t *testing.T) {
+	lines := sortFixture()
+	SortLogLines(lines, SortByDomain, true)
+	if got := order(lines); got != "1234" {
+		t.Errorf("input reordered to %v", got)
+	}
+}
+
+func TestInsertSortedMatchesSortLogLines(t *testing.T) {
+	added := logline.LogLine{Line: "5", Timestamp: sortFixture()[0].Timestamp, LineType: logline.A, Domain: "b.example"}
+	for _, by := range []SortKey{SortByTimestamp, SortByDomain, SortByType} {
+		for _, desc := range []bool{false, true} {
+			want := SortLogLines(append(sortFixture(), added), by, desc)
+			got, i := insertSorted(SortLogLines(sortFixture(), by, desc), added, by, desc)
+			if order(got) != order(want) || got[i].Line != added.Line {
+				t.Errorf("insertSorted(by %v, desc %v) = %v with the new line at %d, want %v", by, desc, order(got), i, order(want))
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// State is the part of a session saved on exit and restored on the next launch
+type State struct {
+	Search        string `json:"search"`         // Text search last applied from the filter field; a leading / marks a regex
+	CaseSensitive bool   `json:"case_sensitive"` // Whether searches match case exactly
+}
+
+func statePath() (string, error) {
+	// statePath returns the location of the state file, e.g. ~/.config/pihole-log-explorer/state.json
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pihole-log-explorer", "state.json"), nil
+}
+
+func SaveState(path string, s State) error {
+	// saveState writes s to path as JSON, creating the parent directory if needed
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func LoadState(path string) (State, error) {
+	// loadState reads the State saved at path
+	// callers should start fresh with a zero State if the file is missing or corrupt
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, err
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, err
+	}
+	return s, nil
+}
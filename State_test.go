@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	// the parent directory doesn't exist yet, as on a first launch
+	path := filepath.Join(t.TempDir(), "pihole-log-explorer", "state.json")
+	for _, want := range []State{
+		{Search: "/^ads\\.", CaseSensitive: true},
+		{Search: "example.com"},
+		{},
+	} {
+		if err := SaveState(path, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadState(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("LoadState after SaveState(%+v) = %+v", want, got)
+		}
+	}
+}
+
+func TestLoadStateMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := LoadState(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadState of a missing file: error %v, want one for a file that doesn't exist", err)
+	}
+	if s != (State{}) {
+		t.Errorf("LoadState of a missing file = %+v, want a zero State", s)
+	}
+}
+
+func TestLoadStateCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"search": "exam`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadState(path)
+	if err == nil {
+		t.Error("LoadState of a truncated file returned no error")
+	}
+	if s != (State{}) {
+		t.Errorf("LoadState of a truncated file = %+v, want a zero State", s)
+	}
+}
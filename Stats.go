@@ -0,0 +1,147 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+// Count is the number of LogLines sharing a single value of some field
+type Count struct {
+	Key   string
+	Count int
+}
+
+// Stats summarizes a slice of LogLines
+// each slice is sorted by descending Count, with ties broken alphabetically by Key
+type Stats struct {
+	Total      int     // Number of LogLines summarized
+	Domains    []Count // Counts by Domain, for lines that have one
+	Requesters []Count // Counts by Requester, for lines that have one
+	LineTypes  []Count // Counts by LineType
+}
+
+func countBy(lines []logline.LogLine, key func(logline.LogLine) string) []Count {
+	// countBy tallies lines by the value returned from key, ignoring empty values
+	counts := make(map[string]int)
+	for i := range lines {
+		if k := key(lines[i]); k != "" {
+			counts[k]++
+		}
+	}
+	return sortCounts(counts)
+}
+
+func sortCounts(counts map[string]int) []Count {
+	// sortCounts orders counts by descending Count, with ties broken alphabetically by Key
+	sorted := make([]Count, 0, len(counts))
+	for k, c := range counts {
+		sorted = append(sorted, Count{Key: k, Count: c})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+func Summarize(lines []logline.LogLine) Stats {
+	// summarize aggregates lines into counts by Domain, Requester, and LineType
+	return Stats{
+		Total:      len(lines),
+		Domains:    countBy(lines, func(ll logline.LogLine) string { return ll.Domain }),
+		Requesters: countBy(lines, func(ll logline.LogLine) string { return ll.Requester }),
+		LineTypes:  countBy(lines, func(ll logline.LogLine) string { return ll.LineType }),
+	}
+}
+
+// Profile summarizes the DNS traffic of a single requester
+type Profile struct {
+	Requester string
+	Queries   int     // Number of queries made by Requester
+	Blocked   int     // Queries answered by a block, e.g. gravity blocked or regex blacklisted
+	Allowed   int     // Queries forwarded upstream or answered locally, e.g. from the cache or /etc/hosts
+	Domains   []Count // Counts of queries by Domain
+}
+
+func (p Profile) BlockRatio() float64 {
+	// blockRatio is the fraction of answered queries that were blocked, or 0 if none were answered
+	if answered := p.Blocked + p.Allowed; answered > 0 {
+		return float64(p.Blocked) / float64(answered)
+	}
+	return 0
+}
+
+func queryOf(ll logline.LogLine) (domain, requester string, ok bool) {
+	// queryOf returns the domain and requester of a query line, including query types without a LineType, e.g. query[HTTPS]
+	switch ll.LineType {
+	case logline.A, logline.AAAA, logline.Ptr:
+		return ll.Domain, ll.Requester, true
+	case logline.Unknown:
+		// query[HTTPS] example.com from 192.168.1.10
+		fields := strings.Fields(ll.Line)
+		if len(fields) > 7 && strings.HasPrefix(fields[4], "query[") && fields[6] == "from" {
+			return fields[5], fields[7], true
+		}
+	}
+	return "", "", false
+}
+
+func answerOf(ll logline.LogLine) (domain string, blocked, ok bool) {
+	// answerOf returns the domain a line answers queries for, and whether it blocked them
+	// forwarding counts as an answer, since a forwarded query wasn't blocked; the reply that follows then finds nothing waiting
+	switch ll.LineType {
+	case logline.Blocked:
+		return ll.Domain, true, true
+	case logline.Forwarded, logline.Cached, logline.Reply, logline.DHCPHost:
+		return ll.Domain, false, true
+	case logline.Unknown:
+		fields := strings.Fields(ll.Line)
+		// regex blacklisted example.com is 0.0.0.0 (also exactly blacklisted, and the newer "denied")
+		if len(fields) > 7 && (fields[5] == "blacklisted" || fields[5] == "denied") && fields[7] == "is" {
+			return fields[6], true, true
+		}
+		// /etc/hosts example.com is 192.168.1.2, and likewise for local.list and config
+		if len(fields) > 6 && fields[6] == "is" && (strings.HasPrefix(fields[4], "/") || fields[4] == "config") {
+			return fields[5], false, true
+		}
+	}
+	return "", false, false
+}
+
+func RequesterProfile(lines []logline.LogLine, requester string) Profile {
+	// requesterProfile aggregates the queries made by requester in lines, which must be in log order
+	// answer lines don't name the requester, so each answer is credited to every query by requester
+	// still waiting on its domain; if clients with different blocking rules ask for a domain at the same time,
+	// they all get the first answer, and a query with no answer in lines is counted as neither blocked nor allowed
+	domains := make(map[string]int)
+	waiting := make(map[string]int)
+	profile := Profile{Requester: requester}
+	for _, ll := range lines {
+		if domain, r, ok := queryOf(ll); ok {
+			if r == requester {
+				profile.Queries++
+				domains[domain]++
+				waiting[domain]++
+			}
+			continue
+		}
+
+		domain, blocked, ok := answerOf(ll)
+		if !ok || waiting[domain] == 0 {
+			continue
+		}
+		if blocked {
+			profile.Blocked += waiting[domain]
+		} else {
+			profile.Allowed += waiting[domain]
+		}
+		delete(waiting, domain)
+	}
+
+	profile.Domains = sortCounts(domains)
+	return profile
+}
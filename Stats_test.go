@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+func TestSummarizeOrdering(t *testing.T) {
+	lines := []logline.LogLine{
+		{LineType: logline.A, Domain: "b.example", Requester: "10.0.0.3"},
+		{LineType: logline.A, Domain: "a.example", Requester: "10.0.0.2"},
+		{LineType: logline.Forwarded, Domain: "c.example"},
+		{LineType: logline.A, Domain: "c.example", Requester: "10.0.0.2"},
+		{LineType: logline.Read},
+		{LineType: logline.Forwarded, Domain: "c.example"},
+	}
+	stats := Summarize(lines)
+
+	if stats.Total != len(lines) {
+		t.Errorf("Total = %d, want %d", stats.Total, len(lines))
+	}
+	// ties are broken alphabetically, and lines without the field aren't counted
+	wantDomains := []Count{{"c.example", 3}, {"a.example", 1}, {"b.example", 1}}
+	if !reflect.DeepEqual(stats.Domains, wantDomains) {
+		t.Errorf("Domains = %v, want %v", stats.Domains, wantDomains)
+	}
+	wantRequesters := []Count{{"10.0.0.2", 2}, {"10.0.0.3", 1}}
+	if !reflect.DeepEqual(stats.Requesters, wantRequesters) {
+		t.Errorf("Requesters = %v, want %v", stats.Requesters, wantRequesters)
+	}
+	wantTypes := []Count{{logline.A, 3}, {logline.Forwarded, 2}, {logline.Read, 1}}
+	if !reflect.DeepEqual(stats.LineTypes, wantTypes) {
+		t.Errorf("LineTypes = %v, want %v", stats.LineTypes, wantTypes)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	stats := Summarize(nil)
+	if stats.Total != 0 || len(stats.Domains) != 0 || len(stats.Requesters) != 0 || len(stats.LineTypes) != 0 {
+		t.Errorf("Summarize(nil) = %+v, want no counts", stats)
+	}
+}
+
+func TestRequesterProfileInterleaved(t *testing.T) {
+	log := `Sep 17 15:04:01 dnsmasq[711]: query[A] ads.example from 10.0.0.2
+Sep 17 15:04:01 dnsmasq[711]: query[A] ads.example from 10.0.0.3
+Sep 17 15:04:01 dnsmasq[711]: gravity blocked ads.example is 0.0.0.0
+Sep 17 15:04:02 dnsmasq[711]: query[HTTPS] news.example from 10.0.0.3
+Sep 17 15:04:02 dnsmasq[711]: query[A] news.example from 10.0.0.2
+Sep 17 15:04:02 dnsmasq[711]: forwarded news.example to 1.1.1.1
+Sep 17 15:04:02 dnsmasq[711]: reply news.example is 93.184.216.34
+Sep 17 15:04:03 dnsmasq[711]: query[A] nas.lan from 10.0.0.3
+Sep 17 15:04:03 dnsmasq[711]: /etc/hosts nas.lan is 192.168.1.5
+Sep 17 15:04:04 dnsmasq[711]: query[A] news.example from 10.0.0.2
+Sep 17 15:04:04 dnsmasq[711]: cached news.example is 93.184.216.34
+Sep 17 15:04:05 dnsmasq[711]: query[A] tracker.example from 10.0.0.3
+Sep 17 15:04:05 dnsmasq[711]: regex blacklisted tracker.example is 0.0.0.0
+Sep 17 15:04:06 dnsmasq[711]: query[AAAA] slow.example from 10.0.0.2
+Sep 17 15:04:07 dnsmasq[711]: query[A] printer.lan from 10.0.0.2
+Sep 17 15:04:07 dnsmasq[711]: DHCP printer.lan is 192.168.1.7
+`
+	lines, err := logline.ParseReader(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []Profile{
+		{Requester: "10.0.0.2", Queries: 5, Blocked: 1, Allowed: 3,
+			Domains: []Count{{"news.example", 2}, {"ads.example", 1}, {"printer.lan", 1}, {"slow.example", 1}}},
+		{Requester: "10.0.0.3", Queries: 4, Blocked: 2, Allowed: 2,
+			Domains: []Count{{"ads.example", 1}, {"nas.lan", 1}, {"news.example", 1}, {"tracker.example", 1}}},
+	}
+	for _, want := range tests {
+		if got := RequesterProfile(lines, want.Requester); !reflect.DeepEqual(got, want) {
+			t.Errorf("RequesterProfile(%v) = %+v, want %+v", want.Requester, got, want)
+		}
+	}
+}
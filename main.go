@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -9,23 +12,257 @@ import (
 	"github.com/rivo/tview"
 
 	"github.com/nxadm/tail"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
 )
 
-func setTable(t *tview.Table, logLines []LogLine) {
+// defaultLogFile is the log path used when neither -logfile nor PIHOLE_LOG is set
+const defaultLogFile = "/var/log/pihole.log"
+
+func logFilePath() string {
+	// logFilePath returns the default log file path, honoring the PIHOLE_LOG environment variable
+	if env := os.Getenv("PIHOLE_LOG"); env != "" {
+		return env
+	}
+	return defaultLogFile
+}
+
+func exportToFile(lines []logline.LogLine, format string) (string, error) {
+	// exportToFile writes lines to a new timestamped file in the working directory and returns its path
+	path := fmt.Sprintf("pihole-log-export-%v.%v", time.Now().Format("20060102-150405"), format)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := ExportLogLines(f, lines, format); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if abs, absError := filepath.Abs(path); absError == nil {
+		path = abs
+	}
+	return path, nil
+}
+
+func caseMode(caseSensitive bool) string {
+	// caseMode describes the search case mode for display
+	if caseSensitive {
+		return "case-sensitive"
+	}
+	return "case-insensitive"
+}
+
+// lineTypeColors are the text colors of table rows by LineType
+// types without an entry, such as Unknown, are drawn in white
+var lineTypeColors = map[string]tcell.Color{
+	logline.Blocked:   tcell.ColorRed,
+	logline.Reply:     tcell.ColorGreen,
+	logline.Cached:    tcell.ColorGreen,
+	logline.Forwarded: tcell.ColorYellow,
+	logline.Read:      tcell.ColorDimGray,
+}
+
+func lineColor(ll logline.LogLine) tcell.Color {
+	// lineColor returns the color to draw ll's row in
+	if color, ok := lineTypeColors[ll.LineType]; ok {
+		return color
+	}
+	return tcell.ColorWhite
+}
+
+// blockedOnlyDescription and highlightedOnlyDescription are shown in the filter indicator
+// for the b and w hotkeys' filters, and identify them in the filter stack
+const (
+	blockedOnlyDescription     = "Blocked only"
+	highlightedOnlyDescription = "Highlighted domains only"
+)
+
+// columnHeaders are the header row of the structured column view
+var columnHeaders = []string{"Timestamp", "Type", "Domain", "Requester"}
+
+func isTagRune(b byte) bool {
+	// isTagRune reports whether b can appear in a tview color or region tag, as matched by tview.Escape
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("_,;: -.\"#", b) >= 0
+}
+
+func escapeTags(s string) string {
+	// escapeTags escapes s like tview.Escape, so tview shows anything that looks like a tag, e.g. [A] in query[A], as written
+	// tview.Escape uses a regular expression, which is slow enough to dominate redrawing a large table
+	var b strings.Builder
+	written := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '[' {
+			continue
+		}
+		j := i + 1
+		for j < len(s) && isTagRune(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+		for j < len(s) && s[j] == '[' {
+			j++
+		}
+		if j < len(s) && s[j] == ']' {
+			if b.Len() == 0 {
+				b.Grow(len(s) + 4)
+			}
+			b.WriteString(s[written:j])
+			b.WriteString("[")
+			written = j
+			i = j
+		}
+	}
+	if written == 0 {
+		return s
+	}
+	b.WriteString(s[written:])
+	return b.String()
+}
+
+func columnValue(ll *logline.LogLine, column int) string {
+	// columnValue returns the field of ll shown in the given column of the structured view, matching columnHeaders
+	// the value is escaped so tview shows any square brackets, e.g. in query[A], as written
+	switch column {
+	case 0:
+		return ll.Timestamp.Format(time.Stamp)
+	case 1:
+		return escapeTags(ll.LineType)
+	case 2:
+		return escapeTags(ll.Domain)
+	default:
+		return escapeTags(ll.Requester)
+	}
+}
+
+// highlightColor is the background of rows whose domain is in the -highlight list
+const highlightColor = tcell.ColorNavy
+
+func lineCell(t *tview.Table, row, column int, ll *logline.LogLine, highlighted map[string]bool) *tview.TableCell {
+	// lineCell returns the cell at row, column ready to be filled in with ll, or nil if it already shows ll
+	// a cell's Reference is the raw Line it was drawn from: every field shown, the row's color and (for a given
+	// highlighted set) its background follow from the Line, so a cell holding the same Line needs no update
+	// existing cells are reused so redraws don't reallocate the whole table
+	// cells we haven't created for a LogLine (missing ones and placeholders) have no Reference and are replaced
+	cell := t.GetCell(row, column)
+	if line, ok := cell.Reference.(string); ok && line == ll.Line {
+		return nil
+	}
+	if cell.Reference == nil {
+		cell = tview.NewTableCell("").SetAlign(tview.AlignLeft)
+		t.SetCell(row, column, cell)
+	}
+	cell.Reference = ll.Line
+	cell.SetTextColor(lineColor(*ll))
+	if logline.DomainInSetLogLine(highlighted)(*ll) {
+		cell.SetBackgroundColor(highlightColor)
+	} else {
+		cell.SetTransparency(true)
+	}
+	return cell
+}
+
+func setRow(t *tview.Table, row int, ll *logline.LogLine, columns bool, highlighted map[string]bool) {
+	// setRow fills in row with ll as setTable draws it, leaving cells that already show ll alone
+	if !columns {
+		if cell := lineCell(t, row, 0, ll, highlighted); cell != nil {
+			cell.SetText(escapeTags(ll.Line))
+		}
+		return
+	}
+	for c := range columnHeaders {
+		if cell := lineCell(t, row, c, ll, highlighted); cell != nil {
+			cell.SetText(columnValue(ll, c))
+		}
+	}
+}
+
+func insertRow(t *tview.Table, row int, ll *logline.LogLine, columns bool, highlighted map[string]bool) {
+	// insertRow adds a row showing ll at row, moving the rows from row on down by one
+	// so a line added to a drawn table doesn't shift every row's contents and force setTable to redraw them all
+	if row < t.GetRowCount() {
+		t.InsertRow(row)
+	}
+	setRow(t, row, ll, columns, highlighted)
+}
+
+func lineAtRow(logLines []logline.LogLine, row int) (logline.LogLine, bool) {
+	// lineAtRow returns the line setTable drew at row from logLines, if there is one
+	if row < 1 || row > len(logLines) {
+		return logline.LogLine{}, false
+	}
+	return logLines[len(logLines)-row], true
+}
+
+func setTable(t *tview.Table, logLines []logline.LogLine, columns bool, highlighted map[string]bool) {
 	// setTable sets the value of the main table based on a slice of logLines
-	t.Clear()
+	// when columns is true, each line is broken into the fields in columnHeaders under a header row
+	// otherwise the raw line is shown in a single column
+	// lines with a Domain in highlighted are drawn on a highlightColor background
+	// the newest line is shown first: table row r (rows start at 1) shows logLines[len(logLines)-r], see lineAtRow
+	// with nothing to show, a placeholder row is drawn instead and selection is disabled
+	//
+	// the table is updated in place rather than rebuilt: rows still showing the same line are skipped,
+	// changed rows reuse their cells, and rows left over from a longer previous view are removed
+	// a view whose rows have moved (after filtering or sorting) still rewrites every row; see insertRow for adding lines
 	rows := len(logLines)
+	if rows == 0 || (t.GetCell(0, 0).Text == columnHeaders[0]) != columns {
+		// switching between the raw and column views changes the shape of every row, so start over
+		t.Clear()
+	}
+
+	if columns {
+		for c, header := range columnHeaders {
+			t.SetCell(0, c,
+				tview.NewTableCell(header).
+					SetTextColor(tcell.ColorYellow).
+					SetAlign(tview.AlignLeft).
+					SetSelectable(false))
+		}
+	}
+
+	if rows == 0 {
+		t.SetSelectable(false, false)
+		t.SetCell(1, 0,
+			tview.NewTableCell("No log entries found").
+				SetTextColor(tcell.ColorGray).
+				SetAlign(tview.AlignLeft).
+				SetSelectable(false))
+		return
+	}
+
 	for r := 1; r <= rows; r++ {
-		t.SetCell(r, 0,
-			tview.NewTableCell(logLines[rows-r].Line).
-				SetTextColor(tcell.ColorWhite).
-				SetAlign(tview.AlignLeft))
+		setRow(t, r, &logLines[rows-r], columns, highlighted)
+	}
+
+	for t.GetRowCount() > rows+1 {
+		t.RemoveRow(t.GetRowCount() - 1)
 	}
 }
 
 func main() {
+	logFile := flag.String("logfile", logFilePath(), "path or glob of the Pi-hole log file(s), .gz files are decompressed (default can also be set with PIHOLE_LOG)")
+	follow := flag.Bool("follow", false, "keep the log file open and append new lines as they are written")
+	year := flag.Int("year", 0, "year of the newest log entry (default: the current year, or last year if its month is still to come)")
+	noRestore := flag.Bool("no-restore", false, "don't restore the last search on startup or save it on exit")
+	highlight := flag.String("highlight", "", "path to a file of domains, one per line, to highlight in the table")
+	flag.Parse()
+
 	app := tview.NewApplication()
 
+	// currentRoot is the primitive last passed to setRoot, so hotkeys for the main view
+	// can be ignored while a modal or another view is shown in its place
+	var currentRoot tview.Primitive
+	setRoot := func(root tview.Primitive, fullscreen bool) {
+		currentRoot = root
+		app.SetRoot(root, fullscreen)
+	}
+
 	table := tview.NewTable().SetBorders(false) // table element
 	table.SetBorder(true).SetTitle("[yellow]PiholeLog")
 
@@ -41,12 +278,28 @@ func main() {
 
 	// filterField is the input box for arbitrary text search
 	filterField := tview.NewInputField().SetFieldWidth(30).SetFieldBackgroundColor(tcell.ColorBlack)
-	filterField.SetTitle("[yellow]Filter string:").SetBorder(true)
+	filterField.SetTitle("[yellow]Filter string (case-insensitive):").SetBorder(true)
+
+	// caseSensitive controls whether text and regex searches match case exactly
+	// searches ignore case by default since domains are case-insensitive
+	caseSensitive := false
+
+	// timeRangeField is the input box for restricting the view to a window of time
+	timeRangeField := tview.NewInputField().SetFieldWidth(30).SetFieldBackgroundColor(tcell.ColorBlack)
+	timeRangeField.SetTitle("[yellow]Time range (e.g. 15:04 - 16:30):").SetBorder(true)
+
+	// searchField is the input box for jumping between matching rows without filtering the rest out
+	searchField := tview.NewInputField().SetFieldWidth(30).SetFieldBackgroundColor(tcell.ColorBlack)
+	searchField.SetTitle("[yellow]Jump to (n/N for next/previous):").SetBorder(true)
 
 	// set up flexbox layout with larger table than detail pane
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(filterField, 3, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(filterField, 0, 1, false).
+			AddItem(timeRangeField, 0, 1, false).
+			AddItem(searchField, 0, 1, false), 3, 1, false,
+		).
 		AddItem(filterIndicator, 3, 1, false).
 		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
 			AddItem(detailPane, 0, 1, false).
@@ -56,70 +309,550 @@ func main() {
 	// helpModal is a modal that displays controls help
 	helpModal := tview.NewModal()
 	helpModal.SetText("Hotkeys:\n" +
-		"* f: enter search string\n" +
-		"* r: reload the log file\n" +
+		"* f: enter search string (prefix with / for a regex, ! or - to exclude matches)\n" +
+		"* i: toggle case-sensitive search\n" +
+		"* t: enter time range, e.g. 15:04 - 16:30\n" +
+		"* /: jump to rows matching a string, n/N: next/previous match\n" +
+		"* r: reload the log file(s)\n" +
+		"* b: toggle showing only blocked queries\n" +
+		"* w: toggle showing only domains from the -highlight list\n" +
+		"* c: toggle between raw lines and columns\n" +
+		"* o: cycle sort order (file, timestamp, domain, type)\n" +
+		"* s: summarize top domains, requesters and entry types\n" +
+		"* e: export the current view to CSV or JSON\n" +
 		"* h: bring up this help pane\n" +
-		"* ESC: clear current filter state\n").
+		"* ESC: remove the most recent filter\n" +
+		"* X or Shift+ESC: clear all filters\n").
 		AddButtons([]string{"Close"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			app.SetRoot(flex, false)
+			setRoot(flex, false)
 		})
 
-	// begin loading log file
-	tf, tailError := tail.TailFile("/var/log/pihole.log", tail.Config{})
+	// messageModal is a modal used to report problems such as a missing log file, or to confirm an action
+	messageModal := tview.NewModal()
+	messageModal.AddButtons([]string{"Close"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			setRoot(flex, false)
+		})
+
+	// exportModal asks which format to export the current view in
+	exportModal := tview.NewModal()
+	exportModal.SetText("Export the current view as:").
+		AddButtons([]string{"CSV", "JSON", "Cancel"})
+
+	// summaryDomains, summaryRequesters and summaryTypes list the most common values in the current view
+	// selecting an entry filters the main table on that value
+	summaryDomains := tview.NewList().ShowSecondaryText(false)
+	summaryDomains.SetBorder(true).SetTitle("[yellow]Top Domains")
+	summaryRequesters := tview.NewList().ShowSecondaryText(false)
+	summaryRequesters.SetBorder(true).SetTitle("[yellow]Top Requesters")
+	summaryTypes := tview.NewList().ShowSecondaryText(false)
+	summaryTypes.SetBorder(true).SetTitle("[yellow]Entry Types")
 
-	if tailError != nil {
-		panic(tailError)
+	// summaryView lays the summary lists out side by side
+	// Tab moves between the lists and ESC returns to the main view
+	summaryView := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(summaryDomains, 0, 2, true).
+		AddItem(summaryRequesters, 0, 1, false).
+		AddItem(summaryTypes, 0, 1, false)
+	summaryView.SetBorder(true)
+
+	summaryLists := []*tview.List{summaryDomains, summaryRequesters, summaryTypes}
+	for i, list := range summaryLists {
+		next := summaryLists[(i+1)%len(summaryLists)]
+		list.SetDoneFunc(func() {
+			setRoot(flex, true)
+		})
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyTab {
+				app.SetFocus(next)
+				return nil
+			}
+			return event
+		})
 	}
 
-	// with this configuration, Tail will spit out the whole file and then stop
+	// profileHeader and profileDomains show the traffic of a single requester
+	// selecting a domain filters the main table on that requester's queries for it
+	profileHeader := tview.NewTextView()
+	profileDomains := tview.NewList().ShowSecondaryText(false)
+	profileDomains.SetBorder(true).SetTitle("[yellow]Top Domains")
+
+	// profileView stacks the requester's totals above its top domains
+	// ESC returns to the main view
+	profileView := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(profileHeader, 2, 0, false).
+		AddItem(profileDomains, 0, 1, true)
+	profileView.SetBorder(true)
+	profileDomains.SetDoneFunc(func() {
+		setRoot(flex, true)
+	})
+
+	// begin loading log file
 	// after we get the initial file parsed, we can proceed to load the state of the initial table
-	// once that is complete, we can enter the main loop and update if I choose to implement that feature
-	var fullLogLines []LogLine
-	for line := range tf.Lines {
-		logLine := UnmarshalLogLine(line.Text)
-		fullLogLines = append(fullLogLines, logLine)
+	// once that is complete, we can enter the main loop, which appends new lines in -follow mode
+	root := tview.Primitive(flex)
+	loaded, loadError := loadLogFiles(*logFile, *year, nil)
+	fullLogLines := loaded.Lines
+	if loadError != nil {
+		messageModal.SetText(fmt.Sprintf("Unable to load %v:\n%v", *logFile, loadError))
+		root = messageModal
+	}
+
+	// highlighted is the set of domains from -highlight; a missing file is reported but doesn't stop the app
+	var highlighted map[string]bool
+	if *highlight != "" {
+		var highlightError error
+		highlighted, highlightError = loadDomainSet(*highlight)
+		if highlightError != nil && loadError == nil {
+			messageModal.SetText(fmt.Sprintf("Unable to load highlighted domains from %v:\n%v", *highlight, highlightError))
+			root = messageModal
+		}
 	}
 
 	// set current view to full log initially
 	currentView := fullLogLines
 
 	// the main table for viewing the unedited log lines will be just one column
-	rows := len(currentView)
-	setTable(table, currentView)
+	// until columnView is toggled on to break the lines into fields
+	// tableLines holds the lines the table was last drawn from, so selections can be mapped back to a LogLine
+	columnView := false
+	tableLines := currentView
+	setTable(table, tableLines, columnView, highlighted)
+
+	// sortOrders are the orders the o key cycles through, starting with file order (newest first)
+	sortOrders := []struct {
+		sorted bool
+		by     SortKey
+		desc   bool
+	}{
+		{sorted: false},
+		{sorted: true, by: SortByTimestamp, desc: false},
+		{sorted: true, by: SortByTimestamp, desc: true},
+		{sorted: true, by: SortByDomain, desc: false},
+		{sorted: true, by: SortByType, desc: false},
+	}
+	sortOrder := 0
+
+	// redrawTable redraws the main table from currentView in the active sort order
+	redrawTable := func() {
+		order := sortOrders[sortOrder]
+		if !order.sorted {
+			table.SetTitle("[yellow]PiholeLog")
+			tableLines = currentView
+			setTable(table, tableLines, columnView, highlighted)
+			return
+		}
+
+		direction := "ascending"
+		if order.desc {
+			direction = "descending"
+		}
+		table.SetTitle(fmt.Sprintf("[yellow]PiholeLog (sorted by %v, %v)", order.by, direction))
+
+		// setTable shows the last line first, so sort in the opposite direction
+		tableLines = SortLogLines(currentView, order.by, !order.desc)
+		setTable(table, tableLines, columnView, highlighted)
+	}
+
+	// showAppended adds ll, just appended to currentView, to the table without redrawing the rows already shown
+	// in a sorted view it is inserted where sorting would put it rather than re-sorting the whole view
+	showAppended := func(ll logline.LogLine) {
+		if len(tableLines) == 0 {
+			// replace the placeholder row
+			redrawTable()
+			return
+		}
+
+		index := len(currentView) - 1
+		if order := sortOrders[sortOrder]; order.sorted {
+			tableLines, index = insertSorted(tableLines, ll, order.by, !order.desc)
+		} else {
+			tableLines = currentView
+		}
+		insertRow(table, len(tableLines)-index, &tableLines[index], columnView, highlighted)
+	}
+
+	// filters is the stack of active filters, combined with AndFilter to produce currentView
+	// filterDescriptions holds the matching text for each filter shown in the filter indicator
+	var filters []logline.FilterFunc
+	var filterDescriptions []string
+
+	// reloading is set while a reload runs in the background, and reloadedLines counts the lines it has read so far
+	reloading := false
+	reloadedLines := 0
+
+	// updateIndicator describes the filter stack and how many entries it lets through, and the progress of any reload
+	updateIndicator := func() {
+		description := "None"
+		if len(filters) > 0 {
+			description = strings.Join(filterDescriptions, " AND ")
+		}
+		indicator := fmt.Sprintf("%v (showing %d of %d entries)", description, len(currentView), len(fullLogLines))
+		if reloading {
+			indicator += fmt.Sprintf(" - Reloading… %d lines", reloadedLines)
+		}
+		filterIndicator.SetText(indicator)
+	}
+	updateIndicator()
+
+	// refreshView re-applies the filter stack to the full log and redraws the table
+	refreshView := func() {
+		if len(filters) == 0 {
+			currentView = fullLogLines
+		} else {
+			currentView = logline.FilterLogLine(fullLogLines, logline.AndFilter(filters...))
+		}
+		updateIndicator()
+		redrawTable()
+	}
+
+	// pushFilter adds f on top of the active filters
+	pushFilter := func(f logline.FilterFunc, description string) {
+		filters = append(filters, f)
+		filterDescriptions = append(filterDescriptions, description)
+		refreshView()
+	}
+
+	// popFilter removes the most recently added filter
+	popFilter := func() {
+		if len(filters) > 0 {
+			filters = filters[:len(filters)-1]
+			filterDescriptions = filterDescriptions[:len(filterDescriptions)-1]
+		}
+		refreshView()
+	}
+
+	// toggleFilter adds f to the active filters, or removes it if a filter with the same description is already active
+	toggleFilter := func(f logline.FilterFunc, description string) {
+		for i := range filterDescriptions {
+			if filterDescriptions[i] == description {
+				filters = append(filters[:i], filters[i+1:]...)
+				filterDescriptions = append(filterDescriptions[:i], filterDescriptions[i+1:]...)
+				refreshView()
+				return
+			}
+		}
+		pushFilter(f, description)
+	}
+
+	// toggleBlockedOnly toggles a filter showing only blocked queries
+	toggleBlockedOnly := func() {
+		toggleFilter(func(ll logline.LogLine) bool {
+			return ll.LineType == logline.Blocked
+		}, blockedOnlyDescription)
+	}
+
+	// toggleHighlightedOnly toggles a filter showing only lines with a highlighted domain
+	toggleHighlightedOnly := func() {
+		if len(highlighted) == 0 {
+			return
+		}
+		toggleFilter(logline.DomainInSetLogLine(highlighted), highlightedOnlyDescription)
+	}
+
+	// clearFilters removes every active filter
+	clearFilters := func() {
+		filters = nil
+		filterDescriptions = nil
+		refreshView()
+	}
+
+	// showSummary fills the summary lists from the current view and displays them
+	showSummary := func() {
+		stats := Summarize(currentView)
+		summaryView.SetTitle(fmt.Sprintf("[yellow]Summary of %d entries", stats.Total))
+
+		// fillSummary adds an entry to list for each count, filtering the main table on field when selected
+		fillSummary := func(list *tview.List, counts []Count, name string, field func(logline.LogLine) string) {
+			list.Clear()
+			for _, c := range counts {
+				key := c.Key
+				list.AddItem(fmt.Sprintf("%6d  %v", c.Count, escapeTags(key)), "", 0, func() {
+					pushFilter(func(ll logline.LogLine) bool {
+						return field(ll) == key
+					}, fmt.Sprintf("%v: %v", name, key))
+					setRoot(flex, true)
+					app.SetFocus(table)
+				})
+			}
+		}
+		fillSummary(summaryDomains, stats.Domains, "Domain", func(ll logline.LogLine) string { return ll.Domain })
+		fillSummary(summaryRequesters, stats.Requesters, "Requester", func(ll logline.LogLine) string { return ll.Requester })
+		fillSummary(summaryTypes, stats.LineTypes, "LineType", func(ll logline.LogLine) string { return ll.LineType })
+
+		setRoot(summaryView, true)
+		app.SetFocus(summaryDomains)
+	}
+
+	// showProfile fills the profile view for requester from every loaded line and displays it
+	// the full log is used rather than the current view, since filters would hide the lines that answer each query
+	showProfile := func(requester string) {
+		profile := RequesterProfile(fullLogLines, requester)
+		profileView.SetTitle(fmt.Sprintf("[yellow]Profile of %v", escapeTags(requester)))
+		profileHeader.SetText(fmt.Sprintf("%d queries, %d blocked, %d allowed (%.1f%% blocked)\n"+
+			"%d queries had no answer in the log",
+			profile.Queries, profile.Blocked, profile.Allowed, 100*profile.BlockRatio(),
+			profile.Queries-profile.Blocked-profile.Allowed))
+
+		profileDomains.Clear()
+		for _, c := range profile.Domains {
+			domain := c.Key
+			profileDomains.AddItem(fmt.Sprintf("%6d  %v", c.Count, escapeTags(domain)), "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.Requester == requester && ll.Domain == domain
+				}, fmt.Sprintf("Requester: %v, Domain: %v", requester, domain))
+				setRoot(flex, true)
+				app.SetFocus(table)
+			})
+		}
+
+		setRoot(profileView, true)
+		app.SetFocus(profileDomains)
+	}
+
+	// exporting writes the current view in the chosen format and confirms where it went
+	exportModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+		var format string
+		switch buttonLabel {
+		case "CSV":
+			format = FormatCSV
+		case "JSON":
+			format = FormatJSON
+		default:
+			setRoot(flex, true)
+			return
+		}
+
+		path, exportError := exportToFile(currentView, format)
+		if exportError != nil {
+			messageModal.SetText(fmt.Sprintf("Unable to export:\n%v", exportError))
+		} else {
+			messageModal.SetText(fmt.Sprintf("Exported %d entries to\n%v", len(currentView), path))
+		}
+		setRoot(messageModal, false)
+	})
+
+	// jumpMatch is the active jump-to search, or nil if none has been entered
+	var jumpMatch logline.FilterFunc
+
+	// jumpToMatch moves the table selection to the next (or previous) row matching jumpMatch
+	jumpToMatch := func(forward bool) {
+		if jumpMatch == nil || len(tableLines) == 0 {
+			return
+		}
+
+		// rows are drawn from the end of tableLines, so moving down the table moves back through the slice
+		// without a selection, GetSelection returns row 0 which maps to len(tableLines), past the last line
+		row, column := table.GetSelection()
+		next := NextMatch(tableLines, jumpMatch, len(tableLines)-row, !forward)
+		if next < 0 {
+			searchField.SetTitle("[yellow]Jump to (no matches):")
+			return
+		}
+		searchField.SetTitle("[yellow]Jump to (n/N for next/previous):")
+		table.SetSelectable(true, true)
+		table.Select(len(tableLines)-next, column)
+	}
+
+	// follower is the tail used in -follow mode to pick up newly written lines
+	var follower *tail.Tail
+
+	// startFollowing begins tailing the newest loaded log file from where loading stopped if -follow was given
+	// tview is not goroutine-safe, so new lines are handed to the main loop with QueueUpdateDraw
+	// an error starting the tail is returned for the caller to show, since the app may not be running yet
+	startFollowing := func(loaded loadedLog) error {
+		if !*follow {
+			return nil
+		}
+
+		tf, tailError := followLogFile(loaded.Newest, loaded.Offset)
+		if tailError != nil {
+			return tailError
+		}
+		follower = tf
+
+		go func() {
+			for line := range tf.Lines {
+				if line.Err != nil {
+					continue
+				}
+				logLine, parseError := logline.UnmarshalLogLine(line.Text)
+				if parseError != nil {
+					continue
+				}
+
+				app.QueueUpdateDraw(func() {
+					// drop lines queued by a follower that has since been stopped
+					if follower != tf {
+						return
+					}
+					// the line's timestamp has no year yet: it follows the last loaded line, or with none, starts in -year or the guessed year
+					followedYear := *year
+					if n := len(fullLogLines); n > 0 {
+						followedYear = logline.YearAfter(fullLogLines[n-1].Timestamp, logLine.Timestamp)
+					} else if followedYear == 0 {
+						followedYear = logline.NewestYear(logLine.Timestamp, time.Now())
+					}
+					logLine.Timestamp = logline.WithYear(logLine.Timestamp, followedYear)
+					fullLogLines = append(fullLogLines, logLine)
+					if len(filters) == 0 {
+						currentView = fullLogLines
+						showAppended(logLine)
+					} else if logline.AndFilter(filters...)(logLine) {
+						currentView = append(currentView, logLine)
+						showAppended(logLine)
+					}
+					updateIndicator()
+				})
+			}
+		}()
+		return nil
+	}
+
+	// stopFollowing stops the running follower, if any
+	stopFollowing := func() {
+		if follower == nil {
+			return
+		}
+		follower.Stop()
+		follower.Cleanup()
+		follower = nil
+	}
+
+	// the app isn't running yet, so a failure to follow is shown by starting on the message instead of the table
+	if loadError == nil {
+		if followError := startFollowing(loaded); followError != nil {
+			messageModal.SetText(fmt.Sprintf("Unable to follow %v:\n%v", loaded.Newest, followError))
+			root = messageModal
+		}
+	}
+
+	// reload re-reads the log files in the background so the UI stays responsive on large logs
+	// progress is reported through QueueUpdateDraw, and the loaded lines replace fullLogLines only once the whole load succeeds
+	// the follower keeps running until then, so a failed reload leaves the view (and -follow) as it was
+	// a reload requested while one is running is ignored
+	reload := func() {
+		if reloading {
+			return
+		}
+		reloading = true
+		reloadedLines = 0
+		updateIndicator()
+
+		go func() {
+			var lastReport time.Time
+			reloaded, reloadError := loadLogFiles(*logFile, *year, func(lines int) {
+				if time.Since(lastReport) < 100*time.Millisecond {
+					return
+				}
+				lastReport = time.Now()
+				app.QueueUpdateDraw(func() {
+					reloadedLines = lines
+					updateIndicator()
+				})
+			})
+
+			app.QueueUpdateDraw(func() {
+				reloading = false
+				if reloadError != nil {
+					updateIndicator()
+					messageModal.SetText(fmt.Sprintf("Unable to reload %v:\n%v", *logFile, reloadError))
+					setRoot(messageModal, false)
+					return
+				}
+
+				stopFollowing()
+				fullLogLines = reloaded.Lines
+				refreshView()
+				if followError := startFollowing(reloaded); followError != nil {
+					messageModal.SetText(fmt.Sprintf("Unable to follow %v:\n%v", reloaded.Newest, followError))
+					setRoot(messageModal, false)
+				}
+			})
+		}()
+	}
 
 	// set up input handling
 	app = app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		// controls for the whole app:
 		// * f key: set focus to input field for arbitrary string search
+		// * t key: set focus to input field for time range filtering
+		// * / key: set focus to input field for jumping to matching rows, n/N: next/previous match
 		// * r key: reload the log file
 		// * h key: help modal
+		// * i key: toggle case-sensitive search
+		// * b key: toggle showing only blocked queries
+		// * w key: toggle showing only highlighted domains
+		// * c key: toggle between the raw and column views
+		// * o key: cycle the sort order
+		// * s key: summary of the current view
+		// * e key: export the current view to a file
+		// * X key or Shift+ESC: clear all filters
+		// these only apply to the main view, so other views and modals get every key
+		if currentRoot != flex {
+			return event
+		}
+		if event.Key() == tcell.KeyEscape && event.Modifiers()&tcell.ModShift != 0 {
+			clearFilters()
+			filterField.SetText("")
+			detailPane.Clear()
+			app.SetFocus(table)
+			return nil
+		}
 		if event.Key() == tcell.KeyRune {
-			if app.GetFocus() != filterField {
+			if _, typing := app.GetFocus().(*tview.InputField); !typing {
 				switch event.Rune() {
 				case 'f':
 					app.SetFocus(filterField)
 					return nil
+				case 't':
+					app.SetFocus(timeRangeField)
+					return nil
+				case '/':
+					app.SetFocus(searchField)
+					return nil
+				case 'n':
+					jumpToMatch(true)
+					return nil
+				case 'N':
+					jumpToMatch(false)
+					return nil
 				case 'r':
-					tf, tailError = tail.TailFile("/var/log/pihole.log", tail.Config{})
-					fullLogLines = make([]LogLine, 0) // clear out logLines
-
-					if tailError != nil {
-						panic(tailError)
-					}
-
-					for line := range tf.Lines {
-						logLine := UnmarshalLogLine(line.Text)
-						fullLogLines = append(fullLogLines, logLine)
-					}
-
-					currentView = fullLogLines
-
-					rows = len(currentView)
-					setTable(table, currentView)
+					reload()
 					return nil
 				case 'h':
-					app.SetRoot(helpModal, false)
+					setRoot(helpModal, false)
+					return nil
+				case 'i':
+					caseSensitive = !caseSensitive
+					filterField.SetTitle(fmt.Sprintf("[yellow]Filter string (%v):", caseMode(caseSensitive)))
+					return nil
+				case 'b':
+					toggleBlockedOnly()
+					return nil
+				case 'w':
+					toggleHighlightedOnly()
+					return nil
+				case 'c':
+					columnView = !columnView
+					redrawTable()
+					return nil
+				case 'o':
+					sortOrder = (sortOrder + 1) % len(sortOrders)
+					redrawTable()
+					return nil
+				case 's':
+					showSummary()
+					return nil
+				case 'e':
+					setRoot(exportModal, false)
+					return nil
+				case 'X':
+					clearFilters()
+					filterField.SetText("")
+					detailPane.Clear()
+					app.SetFocus(table)
 					return nil
 				}
 			}
@@ -127,23 +860,121 @@ func main() {
 		return event // pass any other keys along
 	})
 
+	// lastSearch and lastSearchDescription record the most recent search applied from the filter field
+	// so it can be saved for the next session while it is still active
+	var lastSearch, lastSearchDescription string
+
+	// applySearch pushes a filter for searchKey as typed in the filter field
+	// a leading ! or - excludes matching lines instead, and a leading / (after any negation)
+	// treats the rest of the input as a regular expression
+	applySearch := func(searchKey string) error {
+		var searchFilter logline.FilterFunc
+		var description string
+		query := searchKey
+		negated := strings.HasPrefix(query, "!") || strings.HasPrefix(query, "-")
+		if negated {
+			query = query[1:]
+		}
+		if strings.HasPrefix(query, "/") {
+			pattern := strings.TrimPrefix(query, "/")
+			compiledPattern := pattern
+			if !caseSensitive {
+				compiledPattern = "(?i)" + pattern
+			}
+			regexFilter, regexError := logline.RegexSearchLogLine(compiledPattern)
+			if regexError != nil {
+				return regexError
+			}
+			searchFilter = regexFilter
+			description = fmt.Sprintf("Regex search (%v): %v", caseMode(caseSensitive), pattern)
+		} else if caseSensitive {
+			searchFilter = logline.TextSearchLogLine(query)
+			description = fmt.Sprintf("Text search (%v): %v", caseMode(caseSensitive), query)
+		} else {
+			searchFilter = logline.TextSearchLogLineFold(query)
+			description = fmt.Sprintf("Text search (%v): %v", caseMode(caseSensitive), query)
+		}
+		if negated {
+			searchFilter = logline.NotFilter(searchFilter)
+			description = fmt.Sprintf("NOT (%v)", description)
+		}
+
+		lastSearch, lastSearchDescription = searchKey, description
+		pushFilter(searchFilter, description)
+		return nil
+	}
+
 	filterField.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEscape {
-			currentView = fullLogLines
-			setTable(table, fullLogLines)
-			filterIndicator.SetText("None")
+			popFilter()
 			filterField.SetText("")
 			app.SetFocus(table)
 		} else {
-			searchKey := filterField.GetText()
-			filterIndicator.SetText(fmt.Sprintf("Text search: %v", searchKey))
-			filtered := FilterLogLine(fullLogLines, TextSearchLogLine(searchKey))
-			currentView = filtered
-			setTable(table, currentView)
+			if searchError := applySearch(filterField.GetText()); searchError != nil {
+				filterIndicator.SetText(fmt.Sprintf("Invalid regex: %v", searchError))
+				return
+			}
 			app.SetFocus(table)
 		}
 	})
 
+	// restore the search from the previous session unless -no-restore was given
+	// a missing or corrupt state file just means starting fresh
+	stateFile, stateError := statePath()
+	if !*noRestore && stateError == nil {
+		if state, loadStateError := LoadState(stateFile); loadStateError == nil {
+			caseSensitive = state.CaseSensitive
+			filterField.SetTitle(fmt.Sprintf("[yellow]Filter string (%v):", caseMode(caseSensitive)))
+			if state.Search != "" && applySearch(state.Search) == nil {
+				filterField.SetText(state.Search)
+			}
+		}
+	}
+
+	// ESC leaves the jump-to search without touching the filters
+	searchField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			jumpMatch = nil
+			searchField.SetText("")
+			searchField.SetTitle("[yellow]Jump to (n/N for next/previous):")
+			app.SetFocus(table)
+			return
+		}
+
+		query := searchField.GetText()
+		if caseSensitive {
+			jumpMatch = logline.TextSearchLogLine(query)
+		} else {
+			jumpMatch = logline.TextSearchLogLineFold(query)
+		}
+		app.SetFocus(table)
+		jumpToMatch(true)
+	})
+
+	timeRangeField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			popFilter()
+			timeRangeField.SetText("")
+			app.SetFocus(table)
+			return
+		}
+
+		// bounds without a date are taken to be on the day of the most recent log line
+		ref := time.Now()
+		if len(fullLogLines) > 0 {
+			ref = fullLogLines[len(fullLogLines)-1].Timestamp
+		}
+
+		start, end, rangeError := logline.ParseTimeRange(timeRangeField.GetText(), ref)
+		if rangeError != nil {
+			filterIndicator.SetText(fmt.Sprintf("Invalid time range: %v", rangeError))
+			return
+		}
+		pushFilter(logline.TimeRangeLogLine(start, end),
+			fmt.Sprintf("Time: %v - %v", start.Format(time.Stamp), end.Format(time.Stamp)))
+		app.SetFocus(table)
+	})
+
 	// tcell constants and types used for input handling
 	// * table.Select sets the selected cell
 	// * table.SetFixed sets how many rows and columns are always displayed
@@ -152,13 +983,11 @@ func main() {
 	// * SetSelectable determines whether rows, columns, or cells can be selected
 	table.Select(0, 0).SetFixed(1, 1).SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEscape {
-			currentView = fullLogLines
-			setTable(table, currentView)
-			filterIndicator.SetText("None")
+			popFilter()
 			filterField.SetText("")
 			detailPane.Clear()
 		}
-		if key == tcell.KeyEnter {
+		if key == tcell.KeyEnter && len(tableLines) > 0 {
 			table.SetSelectable(true, true)
 		}
 	}).SetSelectedFunc(func(row int, column int) {
@@ -168,111 +997,149 @@ func main() {
 		// this is working at the moment, but I think I need to create some higher level utilities
 		// to enable filtering more general (e.g. so the user can just type in something to filter)
 
-		rows = len(currentView)
+		// look up the line the row was drawn from rather than indexing currentView,
+		// which may be in a different order or have changed since the table was drawn
+		selectedLine, ok := lineAtRow(tableLines, row)
+		if !ok {
+			return
+		}
 
 		detailPane.Clear()
-		selectedLine := currentView[rows-row]
 
 		// ESC key when in the details pane will clear out the applied filter and return focus to the table
 		detailPane.SetDoneFunc(func() {
 			detailPane.Clear()
-			currentView = fullLogLines
-			setTable(table, currentView)
-			filterIndicator.SetText("None")
+			popFilter()
 			app.SetFocus(table)
 		})
 
 		detailPane.AddItem("Timestamp: "+selectedLine.Timestamp.Format(time.Stamp), "", 0, func() {})
 
 		// when an applicable detailPane list item is selected, filter the main table
-		detailPane.AddItem("Entry type: "+selectedLine.LineType, "", 0, func() {
-			table.Clear()
-
-			// LineType may have a tview-escaped closing square bracket, so we have to undo that
-			filterIndicator.SetText(fmt.Sprintf("LineType: %v",
-				strings.ReplaceAll(selectedLine.LineType, "[]", "]")))
-
-			filtered := FilterLogLine(fullLogLines, func(ll LogLine) bool {
+		detailPane.AddItem("Entry type: "+escapeTags(selectedLine.LineType), "", 0, func() {
+			pushFilter(func(ll logline.LogLine) bool {
 				return ll.LineType == selectedLine.LineType
-			})
-
-			currentView = filtered
-
-			setTable(table, currentView)
+			}, fmt.Sprintf("LineType: %v", selectedLine.LineType))
 			app.SetFocus(table)
 		})
 
 		if selectedLine.Result != "" {
 			detailPane.AddItem("Result: "+selectedLine.Result, "", 0, func() {
-				table.Clear()
-
-				filterIndicator.SetText(fmt.Sprintf("Result: %v", selectedLine.Result))
-
-				filtered := FilterLogLine(fullLogLines, func(ll LogLine) bool {
+				pushFilter(func(ll logline.LogLine) bool {
 					return ll.Result == selectedLine.Result
-				})
+				}, fmt.Sprintf("Result: %v", selectedLine.Result))
+				app.SetFocus(table)
+			})
+		}
 
-				currentView = filtered
+		if selectedLine.RecordType != "" {
+			detailPane.AddItem("Record type: "+selectedLine.RecordType, "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.RecordType == selectedLine.RecordType
+				}, fmt.Sprintf("Record type: %v", selectedLine.RecordType))
+				app.SetFocus(table)
+			})
+		}
 
-				setTable(table, currentView)
+		if selectedLine.TTL != "" {
+			detailPane.AddItem("TTL: "+selectedLine.TTL, "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.TTL == selectedLine.TTL
+				}, fmt.Sprintf("TTL: %v", selectedLine.TTL))
 				app.SetFocus(table)
 			})
 		}
 
 		if selectedLine.Domain != "" {
 			detailPane.AddItem("Domain: "+selectedLine.Domain, "", 0, func() {
-				table.Clear()
-
-				filterIndicator.SetText(fmt.Sprintf("Domain: %v", selectedLine.Domain))
-
-				filtered := FilterLogLine(fullLogLines, func(ll LogLine) bool {
+				pushFilter(func(ll logline.LogLine) bool {
 					return ll.Domain == selectedLine.Domain
-				})
-
-				currentView = filtered
-
-				setTable(table, currentView)
+				}, fmt.Sprintf("Domain: %v", selectedLine.Domain))
 				app.SetFocus(table)
 			})
 		}
 
+		if logline.DomainInSetLogLine(highlighted)(selectedLine) {
+			detailPane.AddItem("Highlighted domain (from "+*highlight+")", "", 0, toggleHighlightedOnly)
+		}
+
 		if selectedLine.Requester != "" {
 			detailPane.AddItem("Requester: "+selectedLine.Requester, "", 0, func() {
-				table.Clear()
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.Requester == selectedLine.Requester
+				}, fmt.Sprintf("Requester: %v", selectedLine.Requester))
+				app.SetFocus(table)
+			})
+			detailPane.AddItem("Requester profile: "+selectedLine.Requester, "", 0, func() {
+				showProfile(selectedLine.Requester)
+			})
+		}
 
-				filterIndicator.SetText(fmt.Sprintf("Requester: %v", selectedLine.Requester))
+		if selectedLine.ClientInfo != "" {
+			detailPane.AddItem("Client info: "+escapeTags(selectedLine.ClientInfo), "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.ClientInfo == selectedLine.ClientInfo
+				}, fmt.Sprintf("Client info: %v", selectedLine.ClientInfo))
+				app.SetFocus(table)
+			})
+		}
 
-				filtered := FilterLogLine(fullLogLines, func(ll LogLine) bool {
-					return ll.Requester == selectedLine.Requester
-				})
+		if selectedLine.MAC != "" {
+			detailPane.AddItem("MAC: "+selectedLine.MAC, "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.MAC == selectedLine.MAC
+				}, fmt.Sprintf("MAC: %v", selectedLine.MAC))
+				app.SetFocus(table)
+			})
+		}
 
-				currentView = filtered
+		if selectedLine.Interface != "" {
+			detailPane.AddItem("Interface: "+selectedLine.Interface, "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.Interface == selectedLine.Interface
+				}, fmt.Sprintf("Interface: %v", selectedLine.Interface))
+				app.SetFocus(table)
+			})
+		}
 
-				setTable(table, currentView)
+		if selectedLine.Hostname != "" {
+			detailPane.AddItem("Hostname: "+selectedLine.Hostname, "", 0, func() {
+				pushFilter(func(ll logline.LogLine) bool {
+					return ll.Hostname == selectedLine.Hostname
+				}, fmt.Sprintf("Hostname: %v", selectedLine.Hostname))
 				app.SetFocus(table)
 			})
 		}
 
 		if selectedLine.Upstream != "" {
 			detailPane.AddItem("Upstream: "+selectedLine.Upstream, "", 0, func() {
-				table.Clear()
-
-				filterIndicator.SetText(fmt.Sprintf("Upstream: %v", selectedLine.Upstream))
-
-				filtered := FilterLogLine(fullLogLines, func(ll LogLine) bool {
+				pushFilter(func(ll logline.LogLine) bool {
 					return ll.Upstream == selectedLine.Upstream
-				})
-
-				currentView = filtered
-
-				setTable(table, currentView)
+				}, fmt.Sprintf("Upstream: %v", selectedLine.Upstream))
 				app.SetFocus(table)
 			})
 		}
 		app.SetFocus(detailPane)
 	})
 
-	if err := app.SetRoot(flex, true).EnableMouse(true).Run(); err != nil {
+	setRoot(root, true)
+	err := app.EnableMouse(true).Run()
+	stopFollowing()
+
+	// save the search for next time if it is still applied
+	if !*noRestore && stateError == nil {
+		state := State{CaseSensitive: caseSensitive}
+		for _, description := range filterDescriptions {
+			if description == lastSearchDescription {
+				state.Search = lastSearch
+			}
+		}
+		if saveError := SaveState(stateFile, state); saveError != nil {
+			fmt.Fprintf(os.Stderr, "unable to save state to %v: %v\n", stateFile, saveError)
+		}
+	}
+
+	if err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/tydar/pihole-log-explorer/pihole/logline"
+)
+
+func TestColumnValueShowsLineTypeAsLogged(t *testing.T) {
+	lineTypes := []string{
+		logline.Blocked, logline.Read, logline.AAAA, logline.A, logline.Ptr,
+		logline.Cached, logline.Forwarded, logline.Reply, logline.Unknown,
+		logline.DHCPDiscover, logline.DHCPOffer, logline.DHCPRequest, logline.DHCPAck,
+		logline.DHCPNak, logline.DHCPRelease, logline.DHCPInform, logline.DHCPDecline, logline.DHCPOther, logline.DHCPHost,
+		logline.DNSSECQuery, logline.DNSSECValidation, logline.RateLimited,
+	}
+	for _, lt := range lineTypes {
+		// a TextView with dynamic colors parses tags the way table cells and lists do
+		view := tview.NewTextView().SetDynamicColors(true)
+		view.SetText(columnValue(&logline.LogLine{LineType: lt}, 1))
+		if got := view.GetText(true); got != lt {
+			t.Errorf("LineType %q is shown as %q", lt, got)
+		}
+	}
+}
+
+func TestSetTableEmpty(t *testing.T) {
+	for _, columns := range []bool{false, true} {
+		for name, lines := range map[string][]logline.LogLine{"nil": nil, "empty": {}} {
+			table := tview.NewTable()
+			// start from a drawn log, as when a reload finds the file emptied
+			setTable(table, []logline.LogLine{{Line: "Sep 17 15:04:01 dnsmasq[1]: read /etc/hosts"}}, columns, nil)
+			table.SetSelectable(true, true)
+			setTable(table, lines, columns, nil)
+
+			if got := table.GetCell(1, 0).Text; got != "No log entries found" {
+				t.Errorf("%v lines, columns %v: row 1 shows %q, want the placeholder", name, columns, got)
+			}
+			if rows := table.GetRowCount(); rows != 2 {
+				t.Errorf("%v lines, columns %v: table has %d rows, want 2 ending with the placeholder", name, columns, rows)
+			}
+			if rowsSelectable, columnsSelectable := table.GetSelectable(); rowsSelectable || columnsSelectable {
+				t.Errorf("%v lines, columns %v: table is still selectable", name, columns)
+			}
+		}
+	}
+}
+
+func TestLineAtRow(t *testing.T) {
+	lines := []logline.LogLine{{Line: "oldest"}, {Line: "newest"}}
+	if ll, ok := lineAtRow(lines, 1); !ok || ll.Line != "newest" {
+		t.Errorf("lineAtRow(lines, 1) = %q, %v, want the newest line", ll.Line, ok)
+	}
+	if ll, ok := lineAtRow(lines, 2); !ok || ll.Line != "oldest" {
+		t.Errorf("lineAtRow(lines, 2) = %q, %v, want the oldest line", ll.Line, ok)
+	}
+
+	for _, tt := range []struct {
+		lines []logline.LogLine
+		row   int
+	}{
+		{lines, 0},  // the header or placeholder row
+		{lines, 3},  // past the end
+		{lines, -1}, // no selection
+		{nil, 1},
+		{[]logline.LogLine{}, 0},
+	} {
+		if _, ok := lineAtRow(tt.lines, tt.row); ok {
+			t.Errorf("lineAtRow(%d lines, %d) found a line", len(tt.lines), tt.row)
+		}
+	}
+}
+
+func tableText(table *tview.Table) []string {
+	var rows []string
+	for r := 0; r < table.GetRowCount(); r++ {
+		var row string
+		for c := 0; c < table.GetColumnCount(); c++ {
+			row += table.GetCell(r, c).Text + "|"
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestInsertRowMatchesSetTable(t *testing.T) {
+	lines := syntheticLog(50)
+	for _, columns := range []bool{false, true} {
+		// newest first, so the appended line goes in at row 1
+		incremental := tview.NewTable()
+		setTable(incremental, lines[:49], columns, nil)
+		insertRow(incremental, 1, &lines[49], columns, nil)
+
+		full := tview.NewTable()
+		setTable(full, lines, columns, nil)
+
+		if got, want := tableText(incremental), tableText(full); !reflect.DeepEqual(got, want) {
+			t.Errorf("columns %v: inserting the newest line drew %q, want %q", columns, got, want)
+		}
+	}
+}
+
+// syntheticLog returns n parsed lines cycling through queries, answers and blocks from a handful of clients
+func syntheticLog(n int) []logline.LogLine {
+	lines := make([]logline.LogLine, 0, n)
+	for i := 0; i < n; i++ {
+		stamp := time.Date(2021, time.September, 17, 0, 0, 0, 0, time.Local).Add(time.Duration(i) * 100 * time.Millisecond).Format(time.Stamp)
+		domain := fmt.Sprintf("host%d.example.com", i%997)
+		var text string
+		switch i % 4 {
+		case 0:
+			text = fmt.Sprintf("%v dnsmasq[711]: query[A] %v from 192.168.1.%d", stamp, domain, i%23)
+		case 1:
+			text = fmt.Sprintf("%v dnsmasq[711]: forwarded %v to 1.1.1.1", stamp, domain)
+		case 2:
+			text = fmt.Sprintf("%v dnsmasq[711]: reply %v is 93.184.216.%d", stamp, domain, i%251)
+		default:
+			text = fmt.Sprintf("%v dnsmasq[711]: gravity blocked %v is 0.0.0.0", stamp, domain)
+		}
+		ll, _ := logline.UnmarshalLogLine(text)
+		lines = append(lines, ll)
+	}
+	logline.ResolveYears(lines, 2021)
+	return lines
+}
+
+// BenchmarkSetTable times redrawing the table over a day-sized log in the raw and column views:
+// drawing it into a new table, redrawing the same view, switching to a filtered view (every row changes),
+// and showing one followed line either with a full redraw or with insertRow
+func BenchmarkSetTable(b *testing.B) {
+	lines := syntheticLog(200000)
+	blocked := logline.FilterLogLine(lines, func(ll logline.LogLine) bool { return ll.LineType == logline.Blocked })
+	last := len(lines) - 1
+
+	for _, columns := range []bool{false, true} {
+		view := "raw"
+		if columns {
+			view = "columns"
+		}
+
+		b.Run(view+"/new", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				setTable(tview.NewTable(), lines, columns, nil)
+			}
+		})
+		b.Run(view+"/unchanged", func(b *testing.B) {
+			table := tview.NewTable()
+			setTable(table, lines, columns, nil)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				setTable(table, lines, columns, nil)
+			}
+		})
+		b.Run(view+"/filter", func(b *testing.B) {
+			table := tview.NewTable()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				setTable(table, lines, columns, nil)
+				b.StartTimer()
+				setTable(table, blocked, columns, nil)
+			}
+		})
+		b.Run(view+"/follow-redraw", func(b *testing.B) {
+			table := tview.NewTable()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				setTable(table, lines[:last], columns, nil)
+				b.StartTimer()
+				setTable(table, lines, columns, nil)
+			}
+		})
+		b.Run(view+"/follow-insert", func(b *testing.B) {
+			table := tview.NewTable()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				setTable(table, lines[:last], columns, nil)
+				b.StartTimer()
+				insertRow(table, 1, &lines[last], columns, nil)
+			}
+		})
+	}
+}
+
+func TestEscapeTagsMatchesTview(t *testing.T) {
+	samples := []string{
+		"", "plain", "Sep 17 15:04:01 dnsmasq[711]: query[A] example.com from 192.168.1.10",
+		"[]", "a]b", "[a", "[a]]", "[[a]]", "[a[[]", "[a[]", "[#ff0000]x[-]", `[a-b.c"#]`, "[a b;c:d,e_f]",
+	}
+	// every short string over tag characters, brackets and a character that can't be in a tag
+	alphabet := []string{"[", "]", "a", "#", "/"}
+	var build func(prefix string, n int)
+	build = func(prefix string, n int) {
+		samples = append(samples, prefix)
+		if n == 0 {
+			return
+		}
+		for _, c := range alphabet {
+			build(prefix+c, n-1)
+		}
+	}
+	build("", 6)
+
+	for _, s := range samples {
+		if got, want := escapeTags(s), tview.Escape(s); got != want {
+			t.Errorf("escapeTags(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// Package logline parses the Pi-hole (dnsmasq) query log into LogLines and filters them.
+// It has no dependency on the TUI, so it can be used on its own, e.g. by an exporter.
+// LineTypes and fields hold the text exactly as logged.
+package logline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Interpreted log line types
+const (
+	Blocked   = "gravity blocked"
+	Read      = "read"
+	AAAA      = "query[AAAA]"
+	A         = "query[A]"
+	Ptr       = "query[PTR]"
+	Cached    = "cached"
+	Forwarded = "forwarded"
+	Reply     = "reply"
+	Unknown   = "unknown"
+
+	// DHCP server messages, logged by dnsmasq-dhcp when Pi-hole is the DHCP server
+	DHCPDiscover = "DHCPDISCOVER"
+	DHCPOffer    = "DHCPOFFER"
+	DHCPRequest  = "DHCPREQUEST"
+	DHCPAck      = "DHCPACK"
+	DHCPNak      = "DHCPNAK"
+	DHCPRelease  = "DHCPRELEASE"
+	DHCPInform   = "DHCPINFORM"
+	DHCPDecline  = "DHCPDECLINE"
+	DHCPOther    = "DHCP" // Any other DHCP server message, e.g. DHCPv6 messages
+
+	// DHCPHost lines answer a query from the hostname of a DHCP lease: DHCP nas.lan is 192.168.1.5
+	DHCPHost = "DHCP host"
+
+	// DNSSEC queries and validation results, logged when DNSSEC is enabled
+	DNSSECQuery      = "dnssec-query"
+	DNSSECValidation = "dnssec validation"
+
+	// RateLimited lines are logged when a client is rate-limited
+	RateLimited = "rate-limiting"
+)
+
+func isDHCPMessage(tok string) bool {
+	// isDHCPMessage reports whether tok names a DHCP server message and its interface, e.g. DHCPACK(eth0)
+	// unlike the bare DHCP token that starts DHCPHost lines
+	return strings.HasPrefix(tok, "DHCP") && strings.Contains(tok, "(")
+}
+
+// dhcpTypes maps the message name at the start of a DHCP line's type token to its LineType
+var dhcpTypes = map[string]string{
+	"DHCPDISCOVER": DHCPDiscover,
+	"DHCPOFFER":    DHCPOffer,
+	"DHCPREQUEST":  DHCPRequest,
+	"DHCPACK":      DHCPAck,
+	"DHCPNAK":      DHCPNak,
+	"DHCPRELEASE":  DHCPRelease,
+	"DHCPINFORM":   DHCPInform,
+	"DHCPDECLINE":  DHCPDecline,
+}
+
+// LogLine is a single parsed line of the log.
+type LogLine struct {
+	Timestamp  time.Time // Timestamp for line
+	LineType   string    // Type of line. Interpreted by UI to determine actions
+	Result     string    // Present for cached, reply, blocked, dnssec validation, DHCP host, and DHCP lines carrying an IP address
+	Domain     string    // Present for cached, reply, blocked, query[*], forwarded, dnssec-query, dnssec validation, DHCP host
+	Requester  string    // Present for query[*], rate-limiting
+	ClientInfo string    // Present for query[*] when extra fields (e.g. port or interface) follow the requester
+	Upstream   string    // Present for forwarded, dnssec-query
+	RecordType string    // Present for reply when the answer's type can be told from it (A, AAAA, CNAME, ...), and dnssec-query
+	TTL        string    // Present for reply when the log includes the answer's TTL
+	MAC        string    // Present for DHCP lines
+	Interface  string    // Present for DHCP lines
+	Hostname   string    // Present for DHCP lines where the client sent one
+	Line       string    // Full text of the line as logged
+}
+
+// minTokens is the fewest whitespace-separated tokens a line can have and still carry a timestamp and type
+const minTokens = 5
+
+// token returns tokens[i], or an empty string if the line is too short to have it
+func token(tokens []string, i int) string {
+	if i < len(tokens) {
+		return tokens[i]
+	}
+	return ""
+}
+
+// ttlPattern matches a TTL trailing a reply, e.g. "ttl=300", "TTL:300" or "(ttl 300)" split over two tokens
+var ttlPattern = regexp.MustCompile(`(?i)^\(?ttl[=:]?(\d*)\)?$`)
+
+// replyRecordType determines the record type of a reply's answer
+// IP answers are A or AAAA records, while other records are logged as their type in angle brackets, e.g. <CNAME>
+// answers such as NXDOMAIN that carry no record have no type
+func replyRecordType(answer string) string {
+	if ip := net.ParseIP(answer); ip != nil {
+		if ip.To4() != nil {
+			return "A"
+		}
+		return "AAAA"
+	}
+	if strings.HasPrefix(answer, "<") && strings.HasSuffix(answer, ">") {
+		return strings.Trim(answer, "<>")
+	}
+	return ""
+}
+
+// replyTTL finds the TTL in the tokens following a reply's answer, if there is one
+func replyTTL(tokens []string) string {
+	for i, t := range tokens {
+		m := ttlPattern.FindStringSubmatch(t)
+		if m == nil {
+			continue
+		}
+		if m[1] != "" {
+			return m[1]
+		}
+		// the value is in the next token, e.g. "ttl 300" or "(ttl 300)"
+		return strings.Trim(token(tokens, i+1), "()")
+	}
+	return ""
+}
+
+// UnmarshalLogLine unmarshals a log line to the struct LogLine.
+// If the line cannot be parsed, an Unknown LogLine holding the raw text is returned along with the error.
+// The Timestamp is left in year 0, since the log doesn't record the year; see ResolveYears and WithYear.
+func UnmarshalLogLine(line string) (LogLine, error) {
+	tokens := strings.Fields(line)
+	unknown := LogLine{LineType: Unknown, Line: line}
+	if len(tokens) < minTokens {
+		return unknown, fmt.Errorf("malformed log line: expected at least %d fields, got %d", minTokens, len(tokens))
+	}
+
+	// parse time
+	// since time.Parse needs an exact string for parsing
+	// we have to reconstruct the timestamp from the tokens
+	timeStr := tokens[0] + " " + tokens[1] + " " + tokens[2]
+	// the log is written in the local timezone of the Pi-hole host
+	timestamp, timeError := time.ParseInLocation(time.Stamp, timeStr, time.Local)
+	if timeError != nil {
+		return unknown, fmt.Errorf("malformed log line timestamp: %w", timeError)
+	}
+
+	// time.Stamp carries no year, so the timestamp is left in year 0 until ResolveYears or WithYear places it
+	// year 0 is a leap year, so a Feb 29 entry keeps its date until the real year is known
+
+	// with log-dhcp, dnsmasq-dhcp puts a transaction ID before the message: 12345 DHCPACK(eth0) ...
+	// drop it so DHCP lines have the same shape either way
+	if strings.Trim(tokens[4], "0123456789") == "" && isDHCPMessage(token(tokens, 5)) {
+		tokens = append(tokens[:4:4], tokens[5:]...)
+	}
+
+	// parse out LineType
+	var lineType string
+
+	switch tokens[4] {
+	case "gravity":
+		lineType = Blocked
+	case "read":
+		lineType = Read
+	case "query[AAAA]":
+		lineType = AAAA
+	case "query[A]":
+		lineType = A
+	case "query[PTR]":
+		lineType = Ptr
+	case "cached":
+		lineType = Cached
+	case "forwarded":
+		lineType = Forwarded
+	case "reply":
+		lineType = Reply
+	case "validation":
+		lineType = DNSSECValidation
+	case "Rate-limiting":
+		lineType = RateLimited
+	case "DHCP":
+		lineType = DHCPHost
+	default:
+		lineType = Unknown
+		if isDHCPMessage(tokens[4]) {
+			lineType = DHCPOther
+			if t, ok := dhcpTypes[strings.SplitN(tokens[4], "(", 2)[0]]; ok {
+				lineType = t
+			}
+		} else if strings.HasPrefix(tokens[4], "dnssec-query[") {
+			lineType = DNSSECQuery
+		}
+	}
+
+	// DHCP lines have their own shape: DHCPACK(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff hostname
+	if isDHCPMessage(tokens[4]) {
+		return unmarshalDHCP(tokens, LogLine{Timestamp: timestamp, LineType: lineType, Line: line}), nil
+	}
+
+	// parse out result for cached, reply, blocked, dnssec validation, and DHCP host answers
+	result := ""
+	if lineType == Cached || lineType == Reply || lineType == DNSSECValidation || lineType == DHCPHost {
+		result = token(tokens, 7)
+	} else if lineType == Blocked { // since blocked lines have "gravity blocked", indicies for later values are moved up by one
+		result = token(tokens, 8)
+	}
+
+	// parse out the record type and TTL of a reply's answer
+	// A/AAAA replies carry an IP (reply example.com is 93.184.216.34)
+	// while CNAME replies carry a placeholder (reply www.example.com is <CNAME>)
+	recordType := ""
+	ttl := ""
+	if lineType == Reply && result != "" {
+		recordType = replyRecordType(result)
+		if len(tokens) > 8 {
+			ttl = replyTTL(tokens[8:])
+		}
+	}
+
+	// dnssec-query lines carry the requested record type in brackets: dnssec-query[DS] com to 1.1.1.1
+	if lineType == DNSSECQuery {
+		recordType = strings.TrimSuffix(strings.TrimPrefix(tokens[4], "dnssec-query["), "]")
+	}
+
+	// parse out Domain for cached, reply, blocked, query[*], forwarded, and DHCP host
+	domain := ""
+	if lineType == Blocked {
+		domain = token(tokens, 6)
+	} else if lineType == Cached || lineType == Reply || lineType == AAAA ||
+		lineType == A || lineType == Ptr || lineType == Forwarded ||
+		lineType == DNSSECQuery || lineType == DNSSECValidation || lineType == DHCPHost {
+		domain = token(tokens, 5)
+	}
+
+	// parse out Requester from query[*] and rate-limiting lines
+	requester := ""
+	if lineType == A || lineType == AAAA || lineType == Ptr {
+		requester = token(tokens, 7)
+	} else if lineType == RateLimited {
+		requester = token(tokens, 5)
+	}
+
+	// parse out any trailing fields after the requester from query[*] lines
+	clientInfo := ""
+	if (lineType == A || lineType == AAAA || lineType == Ptr) && len(tokens) > 8 {
+		clientInfo = strings.Join(tokens[8:], " ")
+	}
+
+	// parse out upstream from forwarded replies and dnssec queries
+	upstream := ""
+	if lineType == Forwarded || lineType == DNSSECQuery {
+		upstream = token(tokens, 7)
+	}
+
+	return LogLine{
+		Timestamp:  timestamp,
+		LineType:   lineType,
+		Result:     result,
+		Domain:     domain,
+		Requester:  requester,
+		ClientInfo: clientInfo,
+		Upstream:   upstream,
+		RecordType: recordType,
+		TTL:        ttl,
+		Line:       line,
+	}, nil
+}
+
+// WithYear returns t moved to the given year.
+func WithYear(t time.Time, year int) time.Time {
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// NewestYear guesses the year of the newest entry of a log read at now.
+// A log can't hold entries from the future, so a month later than now's must be from last year,
+// and a Feb 29 entry must be from the latest leap year.
+func NewestYear(newest, now time.Time) int {
+	year := now.Year()
+	if newest.Month() > now.Month() {
+		year--
+	}
+	if newest.Month() == time.February && newest.Day() == 29 {
+		for time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Day() != 29 {
+			year--
+		}
+	}
+	return year
+}
+
+// ResolveYears sets the year of every Timestamp in lines, which must be in chronological order,
+// given the year of the last line.
+// Since log timestamps carry no year, the month going backwards between two lines
+// (e.g. Dec 31 followed by Jan 1) marks the start of a new year.
+func ResolveYears(lines []LogLine, newestYear int) {
+	// months are compared as parsed, before WithYear can move a Feb 29 into March
+	year := newestYear
+	var nextMonth time.Month
+	for i := len(lines) - 1; i >= 0; i-- {
+		month := lines[i].Timestamp.Month()
+		if i < len(lines)-1 && month > nextMonth {
+			year--
+		}
+		nextMonth = month
+		lines[i].Timestamp = WithYear(lines[i].Timestamp, year)
+	}
+}
+
+// YearAfter returns the year of next, a timestamp logged after prev.
+// As in ResolveYears, the month going backwards means the year has rolled over.
+func YearAfter(prev, next time.Time) int {
+	if next.Month() < prev.Month() {
+		return prev.Year() + 1
+	}
+	return prev.Year()
+}
+
+// unmarshalDHCP fills in the fields of a DHCP line, whose type token names the interface: DHCPACK(eth0)
+// the IP address, MAC address and hostname that follow vary by message, so they are picked out by their form
+func unmarshalDHCP(tokens []string, ll LogLine) LogLine {
+	if open := strings.Index(tokens[4], "("); open >= 0 {
+		ll.Interface = strings.TrimSuffix(tokens[4][open+1:], ")")
+	}
+
+	for i := 5; i < len(tokens); i++ {
+		if ll.Result == "" && ll.MAC == "" && net.ParseIP(tokens[i]) != nil {
+			ll.Result = tokens[i]
+		} else if ll.MAC == "" {
+			if _, err := net.ParseMAC(tokens[i]); err == nil {
+				ll.MAC = tokens[i]
+				// only DHCPACK is followed by the client's hostname; others may be followed by a reason
+				if ll.LineType == DHCPAck {
+					ll.Hostname = token(tokens, i+1)
+				}
+				break
+			}
+		}
+	}
+	return ll
+}
+
+// maxLineSize is the longest line ReadLogLines will accept
+const maxLineSize = 1024 * 1024
+
+// ParseReader reads every LogLine from r with ReadLogLines and resolves their years with ResolveYears,
+// taking the newest line to be from the year guessed by NewestYear.
+func ParseReader(r io.Reader) ([]LogLine, error) {
+	logLines, err := ReadLogLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := len(logLines); n > 0 {
+		ResolveYears(logLines, NewestYear(logLines[n-1].Timestamp, time.Now()))
+	}
+	return logLines, nil
+}
+
+// ReadLogLines reads r line by line and unmarshals each line into a LogLine.
+// Lines that fail to parse (blank lines, rotation headers, etc.) are skipped.
+// Timestamps are left in year 0, so lines read from several files can be merged before calling ResolveYears.
+func ReadLogLines(r io.Reader) ([]LogLine, error) {
+	var logLines []LogLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	for scanner.Scan() {
+		logLine, parseError := UnmarshalLogLine(scanner.Text())
+		if parseError != nil {
+			continue
+		}
+		logLines = append(logLines, logLine)
+	}
+	return logLines, scanner.Err()
+}
+
+// FilterFunc reports whether a LogLine should be included by FilterLogLine.
+type FilterFunc func(LogLine) bool
+
+// FilterLogLine returns the LogLines in lines that f includes, in the same order.
+func FilterLogLine(lines []LogLine, f FilterFunc) []LogLine {
+	var filtered []LogLine
+	for i := range lines {
+		if f(lines[i]) {
+			filtered = append(filtered, lines[i])
+		}
+	}
+	return filtered
+}
+
+// NotFilter inverts f, including exactly the LogLines f excludes.
+func NotFilter(f FilterFunc) FilterFunc {
+	return func(ll LogLine) bool {
+		return !f(ll)
+	}
+}
+
+// AndFilter combines several FilterFuncs into one that includes a LogLine only if every f does.
+// With no FilterFuncs, every LogLine is included.
+func AndFilter(fs ...FilterFunc) FilterFunc {
+	return func(ll LogLine) bool {
+		for _, f := range fs {
+			if !f(ll) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// TextSearchLogLine returns a FilterFunc that searches for text s anywhere in a LogLine's Line.
+func TextSearchLogLine(s string) FilterFunc {
+	return func(ll LogLine) bool {
+		return strings.Contains(ll.Line, s)
+	}
+}
+
+// TextSearchLogLineFold returns a FilterFunc that searches for text s anywhere in a LogLine's Line, ignoring case.
+// The search is compiled once into a case-insensitive regexp, so no lowercased copy of each Line is made.
+func TextSearchLogLineFold(s string) FilterFunc {
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(s))
+	return func(ll LogLine) bool {
+		return re.MatchString(ll.Line)
+	}
+}
+
+// RegexSearchLogLine returns a FilterFunc that matches the regular expression pattern anywhere in a LogLine's Line.
+func RegexSearchLogLine(pattern string) (FilterFunc, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(ll LogLine) bool {
+		return re.MatchString(ll.Line)
+	}, nil
+}
+
+// TimeRangeLogLine returns a FilterFunc that includes LogLines with a Timestamp between start and end, inclusive.
+func TimeRangeLogLine(start, end time.Time) FilterFunc {
+	return func(ll LogLine) bool {
+		return !ll.Timestamp.Before(start) && !ll.Timestamp.After(end)
+	}
+}
+
+// timeRangeLayouts are the accepted formats for each bound of a time range expression
+// layouts without a date take the date of the reference time given to ParseTimeRange
+var timeRangeLayouts = []struct {
+	layout    string
+	hasDate   bool
+	precision time.Duration
+}{
+	{time.Stamp, true, time.Second},
+	{"Jan _2 15:04", true, time.Minute},
+	{"15:04:05", false, time.Second},
+	{"15:04", false, time.Minute},
+}
+
+// parseTimeBound parses a single bound of a time range expression in the year (and, if not given, date) of ref
+func parseTimeBound(s string, ref time.Time) (time.Time, time.Duration, error) {
+	for _, l := range timeRangeLayouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+		year, month, day := ref.Date()
+		if l.hasDate {
+			month, day = t.Month(), t.Day()
+		}
+		return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, ref.Location()), l.precision, nil
+	}
+	return time.Time{}, 0, fmt.Errorf("unrecognized time %q, expected e.g. \"15:04\" or \"Jan 2 15:04:05\"", s)
+}
+
+// ParseTimeRange parses an expression like "15:04 - 16:30" or "Sep 17 15:04 - Sep 17 16:30" into its bounds,
+// taking the year, and the date if not given, from ref.
+// The end bound is extended to cover its whole minute or second, so "16:30" includes 16:30:59.
+func ParseTimeRange(expr string, ref time.Time) (time.Time, time.Time, error) {
+	bounds := strings.Split(expr, "-")
+	if len(bounds) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected a range of the form \"start - end\"")
+	}
+
+	start, _, err := parseTimeBound(strings.TrimSpace(bounds[0]), ref)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, precision, err := parseTimeBound(strings.TrimSpace(bounds[1]), ref)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end = end.Add(precision - time.Nanosecond)
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range end is before range start")
+	}
+	return start, end, nil
+}
+
+// DomainInSetLogLine returns a FilterFunc that includes LogLines whose Domain is in set.
+// Set keys are expected to be lowercase.
+func DomainInSetLogLine(set map[string]bool) FilterFunc {
+	return func(ll LogLine) bool {
+		return ll.Domain != "" && set[strings.ToLower(ll.Domain)]
+	}
+}
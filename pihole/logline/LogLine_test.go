@@ -0,0 +1,307 @@
+package logline
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveYearsKeepsLeapDay(t *testing.T) {
+	lines := []LogLine{
+		mustUnmarshal(t, "Dec 31 23:59:59 dnsmasq[1]: query[A] example.com from 10.0.0.2"),
+		mustUnmarshal(t, "Feb 29 10:00:00 dnsmasq[1]: query[A] example.com from 10.0.0.2"),
+	}
+	ResolveYears(lines, 2024)
+
+	want := []time.Time{
+		time.Date(2023, time.December, 31, 23, 59, 59, 0, time.Local),
+		time.Date(2024, time.February, 29, 10, 0, 0, 0, time.Local),
+	}
+	for i := range lines {
+		if !lines[i].Timestamp.Equal(want[i]) {
+			t.Errorf("line %d: got %v, want %v", i, lines[i].Timestamp, want[i])
+		}
+	}
+}
+
+func TestNewestYearLeapDay(t *testing.T) {
+	newest := mustUnmarshal(t, "Feb 29 10:00:00 dnsmasq[1]: query[A] example.com from 10.0.0.2").Timestamp
+	now := time.Date(2026, time.October, 14, 0, 0, 0, 0, time.Local)
+	if got := NewestYear(newest, now); got != 2024 {
+		t.Errorf("NewestYear(Feb 29, %v) = %d, want 2024", now, got)
+	}
+}
+
+func mustUnmarshal(t *testing.T, line string) LogLine {
+	t.Helper()
+	ll, err := UnmarshalLogLine(line)
+	if err != nil {
+		t.Fatalf("UnmarshalLogLine(%q): %v", line, err)
+	}
+	return ll
+}
+
+func TestUnmarshalLogLineNewTypes(t *testing.T) {
+	const mac = "aa:bb:cc:dd:ee:ff"
+	tests := []struct {
+		line string
+		want LogLine // every field but Timestamp and Line
+	}{
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPDISCOVER(eth0) aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPDiscover, MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPOFFER(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPOffer, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPREQUEST(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPRequest, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPACK(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff laptop",
+			LogLine{LineType: DHCPAck, Result: "192.168.1.50", MAC: mac, Interface: "eth0", Hostname: "laptop"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: 3521740147 DHCPACK(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff laptop",
+			LogLine{LineType: DHCPAck, Result: "192.168.1.50", MAC: mac, Interface: "eth0", Hostname: "laptop"}},
+		// the reason after the MAC address isn't a hostname
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPNAK(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff wrong network",
+			LogLine{LineType: DHCPNak, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPRELEASE(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPRelease, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPINFORM(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPInform, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPDECLINE(eth0) 192.168.1.50 aa:bb:cc:dd:ee:ff",
+			LogLine{LineType: DHCPDecline, Result: "192.168.1.50", MAC: mac, Interface: "eth0"}},
+		// DHCPv6 clients are named by a DUID rather than a MAC address
+		{"Sep 17 15:04:01 dnsmasq-dhcp[512]: DHCPREPLY(eth0) fd00::50 00:01:00:01:28:f5:aa:bb:cc:dd:ee:ff laptop",
+			LogLine{LineType: DHCPOther, Result: "fd00::50", Interface: "eth0"}},
+		{"Sep 17 15:04:01 dnsmasq[512]: DHCP nas.lan is 192.168.1.5",
+			LogLine{LineType: DHCPHost, Result: "192.168.1.5", Domain: "nas.lan"}},
+		{"Sep 17 15:04:01 dnsmasq[512]: dnssec-query[DS] com to 1.1.1.1",
+			LogLine{LineType: DNSSECQuery, RecordType: "DS", Domain: "com", Upstream: "1.1.1.1"}},
+		{"Sep 17 15:04:01 dnsmasq[512]: validation example.com is SECURE",
+			LogLine{LineType: DNSSECValidation, Result: "SECURE", Domain: "example.com"}},
+		{"Sep 17 15:04:01 dnsmasq[512]: Rate-limiting 10.0.0.5 is REFUSED (EDE: rate limited)",
+			LogLine{LineType: RateLimited, Requester: "10.0.0.5"}},
+	}
+
+	for _, tt := range tests {
+		got := mustUnmarshal(t, tt.line)
+		got.Timestamp, got.Line = time.Time{}, ""
+		if got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestReplyRecordType(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"93.184.216.34", "A"},
+		{"2606:2800:220:1:248:1893:25c8:1946", "AAAA"},
+		{"::ffff:93.184.216.34", "A"},
+		{"<CNAME>", "CNAME"},
+		{"<HTTPS>", "HTTPS"},
+		{"NXDOMAIN", ""},
+		{"NODATA-IPv6", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := replyRecordType(tt.answer); got != tt.want {
+			t.Errorf("replyRecordType(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestReplyTTL(t *testing.T) {
+	tests := []struct {
+		tokens []string
+		want   string
+	}{
+		{[]string{"ttl=300"}, "300"},
+		{[]string{"TTL:300"}, "300"},
+		{[]string{"(ttl", "300)"}, "300"},
+		{[]string{"ttl", "300"}, "300"},
+		{[]string{"(ttl=300)"}, "300"},
+		{[]string{"(DNSSEC)", "ttl=60"}, "60"},
+		{[]string{"ttl"}, ""}, // value cut off
+		{[]string{"(DNSSEC)"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := replyTTL(tt.tokens); got != tt.want {
+			t.Errorf("replyTTL(%q) = %q, want %q", tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalLogLineReply(t *testing.T) {
+	tests := []struct {
+		line string
+		want LogLine // every field but Timestamp and Line
+	}{
+		{"Sep 17 15:04:01 dnsmasq[711]: reply example.com is 93.184.216.34",
+			LogLine{LineType: Reply, Domain: "example.com", Result: "93.184.216.34", RecordType: "A"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: reply example.com is 2606:2800:220:1:248:1893:25c8:1946 ttl=300",
+			LogLine{LineType: Reply, Domain: "example.com", Result: "2606:2800:220:1:248:1893:25c8:1946", RecordType: "AAAA", TTL: "300"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: reply www.example.com is <CNAME> (ttl 300)",
+			LogLine{LineType: Reply, Domain: "www.example.com", Result: "<CNAME>", RecordType: "CNAME", TTL: "300"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: reply nothere.example is NXDOMAIN",
+			LogLine{LineType: Reply, Domain: "nothere.example", Result: "NXDOMAIN"}},
+		// truncated lines keep what they have
+		{"Sep 17 15:04:01 dnsmasq[711]: reply x.com is",
+			LogLine{LineType: Reply, Domain: "x.com"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: reply",
+			LogLine{LineType: Reply}},
+	}
+	for _, tt := range tests {
+		got := mustUnmarshal(t, tt.line)
+		got.Timestamp, got.Line = time.Time{}, ""
+		if got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalLogLineMalformed(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr bool
+		want    LogLine // every field but Timestamp and Line
+	}{
+		{"", true, LogLine{LineType: Unknown}},
+		{"   ", true, LogLine{LineType: Unknown}},
+		{"Sep 17 15:04:01 dnsmasq[711]:", true, LogLine{LineType: Unknown}},
+		{"----- pihole.log rotated -----", true, LogLine{LineType: Unknown}},
+		{"Sep 32 15:04:01 dnsmasq[711]: query[A] example.com from 10.0.0.2", true, LogLine{LineType: Unknown}},
+		{"2021-09-17 15:04:01 dnsmasq[711]: query[A] example.com from 10.0.0.2", true, LogLine{LineType: Unknown}},
+		// a query line missing its requester still parses
+		{"Sep 17 15:04:01 dnsmasq[711]: query[A] example.com", false,
+			LogLine{LineType: A, Domain: "example.com"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: query[A] example.com from 10.0.0.2", false,
+			LogLine{LineType: A, Domain: "example.com", Requester: "10.0.0.2"}},
+		// anything after the requester is kept as ClientInfo
+		{"Sep 17 15:04:01 dnsmasq[711]: query[AAAA] example.com from 10.0.0.2#53124", false,
+			LogLine{LineType: AAAA, Domain: "example.com", Requester: "10.0.0.2#53124"}},
+		{"Sep 17 15:04:01 dnsmasq[711]: query[PTR] 2.0.0.10.in-addr.arpa from 10.0.0.2 port 53124 eth0", false,
+			LogLine{LineType: Ptr, Domain: "2.0.0.10.in-addr.arpa", Requester: "10.0.0.2", ClientInfo: "port 53124 eth0"}},
+	}
+	for _, tt := range tests {
+		got, err := UnmarshalLogLine(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalLogLine(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+		}
+		if got.Line != tt.line {
+			t.Errorf("UnmarshalLogLine(%q) kept Line %q", tt.line, got.Line)
+		}
+		got.Timestamp, got.Line = time.Time{}, ""
+		if got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestReadLogLinesSkipsMalformed(t *testing.T) {
+	log := `Sep 17 15:04:01 dnsmasq[711]: query[A] example.com from 10.0.0.2
+
+----- pihole.log rotated -----
+Sep 17 15:04:01 dnsmasq[711]:
+Sep 32 15:04:02 dnsmasq[711]: forwarded example.com to 1.1.1.1
+Sep 17 15:04:02 dnsmasq[711]: reply example.com is 93.184.216.34
+`
+	lines, err := ReadLogLines(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].LineType != A || lines[1].LineType != Reply {
+		t.Errorf("ReadLogLines kept %+v, want only the query and the reply", lines)
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	ref := time.Date(2021, time.September, 18, 12, 0, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, min, sec, nsec int) time.Time {
+		return time.Date(2021, month, day, hour, min, sec, nsec, time.Local)
+	}
+
+	tests := []struct {
+		expr       string
+		start, end time.Time
+	}{
+		// bounds without a date are on ref's date
+		{"15:04 - 16:30", at(time.September, 18, 15, 4, 0, 0), at(time.September, 18, 16, 30, 59, 999999999)},
+		{"15:04:05-16:30:15", at(time.September, 18, 15, 4, 5, 0), at(time.September, 18, 16, 30, 15, 999999999)},
+		// bounds with a date keep it, in ref's year
+		{"Sep 17 15:04 - Sep 17 16:30", at(time.September, 17, 15, 4, 0, 0), at(time.September, 17, 16, 30, 59, 999999999)},
+		{"Jan  2 15:04:05 - Sep 17 16:30", at(time.January, 2, 15, 4, 5, 0), at(time.September, 17, 16, 30, 59, 999999999)},
+		{"16:30 - 16:30", at(time.September, 18, 16, 30, 0, 0), at(time.September, 18, 16, 30, 59, 999999999)},
+	}
+	for _, tt := range tests {
+		start, end, err := ParseTimeRange(tt.expr, ref)
+		if err != nil {
+			t.Errorf("ParseTimeRange(%q): %v", tt.expr, err)
+			continue
+		}
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("ParseTimeRange(%q) = %v, %v, want %v, %v", tt.expr, start, end, tt.start, tt.end)
+		}
+	}
+
+	for _, expr := range []string{
+		"16:30 - 15:04", // end before start
+		"Sep 17 16:30 - Sep 16 16:30",
+		"15:04",
+		"15:04 - 16:30 - 17:00",
+		"noon - 16:30",
+		"15:04 - 25:00",
+		"",
+	} {
+		if start, end, err := ParseTimeRange(expr, ref); err == nil {
+			t.Errorf("ParseTimeRange(%q) = %v, %v, want an error", expr, start, end)
+		}
+	}
+}
+
+func TestTimeRangeLogLineInclusive(t *testing.T) {
+	ref := time.Date(2021, time.September, 17, 12, 0, 0, 0, time.Local)
+	start, end, err := ParseTimeRange("15:04 - 16:30", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	include := TimeRangeLogLine(start, end)
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Sep 17 15:03:59 dnsmasq[711]: query[A] example.com from 10.0.0.2", false},
+		{"Sep 17 15:04:00 dnsmasq[711]: query[A] example.com from 10.0.0.2", true},
+		{"Sep 17 16:30:59 dnsmasq[711]: query[A] example.com from 10.0.0.2", true},
+		{"Sep 17 16:31:00 dnsmasq[711]: query[A] example.com from 10.0.0.2", false},
+		{"Sep 16 15:30:00 dnsmasq[711]: query[A] example.com from 10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		ll := mustUnmarshal(t, tt.line)
+		ll.Timestamp = WithYear(ll.Timestamp, 2021)
+		if got := include(ll); got != tt.want {
+			t.Errorf("%q: included %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTextSearchLogLineFold(t *testing.T) {
+	ll := LogLine{Line: "Sep 17 15:04:01 dnsmasq[711]: query[AAAA] Example.COM from 10.0.0.2"}
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com", true},
+		{"query[aaaa]", true}, // brackets are matched literally
+		{"example.com from", true},
+		{"examplexcom", false}, // so is the dot
+		{"query[a]", false},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if got := TextSearchLogLineFold(tt.s)(ll); got != tt.want {
+			t.Errorf("TextSearchLogLineFold(%q) matched %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}